@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kazz187/fargate-td/internal/config"
+	"github.com/kazz187/fargate-td/internal/report"
+)
+
+// ConfirmOptions are the options to confirm the changes before applying them.
+type ConfirmOptions struct {
+	Yes             bool
+	ConfirmClusters []string
+}
+
+func SetConfirmOptions(c *cobra.Command, o *ConfirmOptions) {
+	c.Flags().BoolVarP(&o.Yes, "yes", "y", false, "apply changes without confirmation")
+	c.Flags().StringSliceVar(&o.ConfirmClusters, "confirm-cluster", nil, "name of protected cluster to deploy to with --yes")
+}
+
+// errCanceled is returned when the changes are not confirmed.
+var errCanceled = errors.New("deploy is canceled")
+
+// confirm shows the summary of the changes and asks for confirmation if the input of the command is a terminal.
+// Protected clusters require typing the cluster name, or --yes with --confirm-cluster in non-interactive runs.
+func (o *ConfirmOptions) confirm(c *cobra.Command, rep *report.Report, deployConf *config.DeployConfig) error {
+	var changed []*report.Target
+	var protected []string
+	for _, t := range rep.Targets {
+		if t.Status == report.StatusUpToDate {
+			continue
+		}
+		changed = append(changed, t)
+		if deployConf.IsProtected(t.Cluster) && !slices.Contains(protected, t.Cluster) {
+			protected = append(protected, t.Cluster)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	fmt.Printf("%d of %d targets will be updated:\n", len(changed), len(rep.Targets))
+	for _, t := range changed {
+		fmt.Printf("  %s %s\n", t.Label(), t.Status)
+		for _, s := range t.Summary {
+			fmt.Printf("    %s\n", s)
+		}
+		for _, n := range t.Notes {
+			fmt.Printf("    %s\n", n.Message)
+		}
+	}
+
+	if o.Yes {
+		for _, cluster := range protected {
+			if !slices.Contains(o.ConfirmClusters, cluster) {
+				return fmt.Errorf("cluster %s is protected, --confirm-cluster %s is required with --yes", cluster, cluster)
+			}
+		}
+		return nil
+	}
+	// The answer is read from the input of the command, so other readers than a terminal are non-interactive
+	f, ok := c.InOrStdin().(*os.File)
+	if !ok || !isTerminal(f) {
+		if len(protected) != 0 {
+			return fmt.Errorf("refused to deploy to protected cluster %s in non-interactive run, --yes and --confirm-cluster are required", strings.Join(protected, ", "))
+		}
+		return nil
+	}
+	in := bufio.NewReader(c.InOrStdin())
+	if len(protected) == 0 {
+		answer, err := ask(in, "Apply these changes? [y/N]: ")
+		if err != nil {
+			return err
+		}
+		if answer := strings.ToLower(answer); answer != "y" && answer != "yes" {
+			return errCanceled
+		}
+		return nil
+	}
+	for _, cluster := range protected {
+		answer, err := ask(in, fmt.Sprintf("Cluster %s is protected. Type the cluster name to apply these changes: ", au.Bold(cluster)))
+		if err != nil {
+			return err
+		}
+		if answer != cluster {
+			return errCanceled
+		}
+	}
+	return nil
+}
+
+func ask(in *bufio.Reader, prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kazz187/fargate-td/internal/config"
+	"github.com/kazz187/fargate-td/internal/report"
+)
+
+func TestConfirmNonInteractiveReader(t *testing.T) {
+	dir := t.TempDir()
+	conf := `clusters:
+  - name: production
+    protected: true
+    services:
+      - name: web
+        task: web
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yml"), []byte(conf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deployConf := config.NewDeployConfig()
+	if err := deployConf.Load(dir); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	rep := report.New("Deploy web")
+	rep.Add(report.KindService, "production", "web").Status = report.StatusChanged
+
+	// The answer of the reader is not read, even if it confirms the cluster
+	c := &cobra.Command{}
+	c.SetIn(strings.NewReader("production\n"))
+	o := &ConfirmOptions{}
+	err := o.confirm(c, rep, deployConf)
+	if err == nil || errors.Is(err, errCanceled) {
+		t.Fatalf("confirm() error = %v, want refusal of the protected cluster", err)
+	}
+	if !strings.Contains(err.Error(), "non-interactive") {
+		t.Errorf("confirm() error = %v, want refusal in non-interactive run", err)
+	}
+}
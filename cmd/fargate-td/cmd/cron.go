@@ -0,0 +1,471 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchevents"
+	cwetypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchevents/types"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	schtypes "github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/kazz187/fargate-td/internal/config"
+)
+
+func CronCommand(ftr *FargateTdRunner) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "cron",
+		Short: "Manage cron jobs",
+		Long: `Manage cron jobs
+
+Run 'fargate-td cron COMMAND -p PATH -t TASK
+
+    $ fargate-td cron disable -p app1/development -t task1 --job job1`,
+	}
+	c.AddCommand(CronStateCommand(ftr, config.CronJobStateEnabled))
+	c.AddCommand(CronStateCommand(ftr, config.CronJobStateDisabled))
+	c.AddCommand(CronMigrateCommand(ftr))
+	return c
+}
+
+func SetCronOptions(c *cobra.Command, ftr *FargateTdRunner, r *CronRunner) {
+	c.Flags().StringVarP(&r.TaskName, "task", "t", "", "task name")
+	_ = c.MarkFlagRequired("task")
+	c.Flags().StringVarP(&r.TargetTaskPath, "path", "p", "", "target path")
+	_ = c.MarkFlagRequired("path")
+	c.Flags().StringVarP(&r.ProjectRootPath, "root_path", "r", "", "project root path")
+	c.Flags().StringVar(&r.JobName, "job", "", "cron job name (default: all cron jobs of the task)")
+	c.Flags().BoolVarP(&ftr.Debug, "debug", "d", false, "debug option")
+}
+
+type CronRunner struct {
+	Command         *cobra.Command
+	TaskName        string
+	TargetTaskPath  string
+	ProjectRootPath string
+	JobName         string
+}
+
+func (r *CronRunner) preRunE(c *cobra.Command, args []string) error {
+	if r.ProjectRootPath == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		r.ProjectRootPath = wd
+	} else {
+		var err error
+		r.ProjectRootPath, err = filepath.Abs(r.ProjectRootPath)
+		if err != nil {
+			return err
+		}
+	}
+	// Must contain prefix "/"
+	r.TargetTaskPath = filepath.Clean("/" + r.TargetTaskPath)
+	if strings.Contains(r.TaskName, "/") {
+		return fmt.Errorf(`invalid task name (contains "/")`)
+	}
+	return nil
+}
+
+// CronJobs returns the cron jobs of the task, filtered by the job name if it is specified.
+func (r *CronRunner) CronJobs() ([]config.CronJobTaskConfig, error) {
+	deployConf, err := loadDeployConfig(r.ProjectRootPath, r.TargetTaskPath)
+	if err != nil {
+		return nil, err
+	}
+	cronJobs := deployConf.GetCronJobTaskConfigs(r.TaskName)
+	if r.JobName == "" {
+		return cronJobs, nil
+	}
+	for _, job := range cronJobs {
+		if job.CronJob == r.JobName {
+			return []config.CronJobTaskConfig{job}, nil
+		}
+	}
+	return nil, fmt.Errorf("cron job %s of task %s is not found", r.JobName, r.TaskName)
+}
+
+// cronStateVerbs are the subcommands of cron which change cron jobs to the state.
+var cronStateVerbs = map[string]struct{ verb, title string }{
+	config.CronJobStateEnabled:  {verb: "enable", title: "Enable"},
+	config.CronJobStateDisabled: {verb: "disable", title: "Disable"},
+}
+
+func CronStateCommand(ftr *FargateTdRunner, state string) *cobra.Command {
+	r := &CronStateRunner{
+		State: state,
+	}
+	verb, title := cronStateVerbs[state].verb, cronStateVerbs[state].title
+	c := &cobra.Command{
+		Use:   verb + " -p PATH -t TASK [--job NAME]",
+		Short: title + " cron jobs temporarily",
+		Long: title + ` cron jobs temporarily
+
+The state of cron jobs is changed without changing config.yml.
+Set 'state: ` + state + `' to the cron jobs in config.yml to change it permanently.
+
+Run 'fargate-td cron ` + verb + ` -p PATH -t TASK [--job NAME]
+
+    $ fargate-td cron ` + verb + ` -p app1/development -t task1 --job job1`,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	SetCronOptions(c, ftr, &r.CronRunner)
+	r.Command = c
+	return c
+}
+
+type CronStateRunner struct {
+	CronRunner
+	State string
+}
+
+func (r *CronStateRunner) runE(c *cobra.Command, args []string) error {
+	ctx := context.Background()
+	cronJobs, err := r.CronJobs()
+	if err != nil {
+		return err
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+	cweSvc := cloudwatchevents.NewFromConfig(cfg)
+	schSvc := scheduler.NewFromConfig(cfg)
+
+	var failedCronJobList []string
+	for _, job := range cronJobs {
+		if job.UsesScheduler() {
+			err = r.setScheduleState(ctx, schSvc, job)
+		} else {
+			err = setRuleState(ctx, cweSvc, job.CronJob, ruleState(r.State, ""))
+		}
+		if err != nil {
+			logrus.Errorf("failed to change state of cron job: %s", err)
+			failedCronJobList = append(failedCronJobList, "[cluster: "+job.Cluster+", cron job: "+job.CronJob+"]")
+			continue
+		}
+		fmt.Printf("Cron job is %s [cluster: %s, cronJob: %s]\n", r.State, job.Cluster, job.CronJob)
+		confState := job.State
+		if confState == "" {
+			confState = config.CronJobStateEnabled
+		}
+		if confState != r.State {
+			logrus.Warnf("the state of cron job %s differs from config.yml (config: %s, live: %s)", job.CronJob, confState, r.State)
+		}
+	}
+	if len(failedCronJobList) != 0 {
+		return fmt.Errorf("failed to change state of cron jobs: %s", strings.Join(failedCronJobList, ", "))
+	}
+	return nil
+}
+
+func (r *CronStateRunner) setScheduleState(ctx context.Context, schSvc *scheduler.Client, job config.CronJobTaskConfig) error {
+	schedule, err := getSchedule(ctx, schSvc, job)
+	if err != nil {
+		return fmt.Errorf("failed to get schedule: %w", err)
+	}
+	if schedule == nil {
+		return fmt.Errorf("schedule %s is not found in group %s", job.CronJob, job.ScheduleGroup)
+	}
+	in := scheduleInputFromOutput(schedule)
+	in.State = scheduleState(r.State, "")
+	_, err = schSvc.UpdateSchedule(ctx, in)
+	return err
+}
+
+func CronMigrateCommand(ftr *FargateTdRunner) *cobra.Command {
+	r := &CronMigrateRunner{}
+	c := &cobra.Command{
+		Use:   "migrate -p PATH -t TASK [--job NAME]",
+		Short: "Migrate CloudWatch Events rules to EventBridge Scheduler schedules",
+		Long: `Migrate CloudWatch Events rules to EventBridge Scheduler schedules
+
+Create a schedule with the same expression and ECS target as the rule of the cron job,
+and disable the rule. Set 'scheduler: eventbridge-scheduler' to the cron job in config.yml after migration.
+
+Run 'fargate-td cron migrate -p PATH -t TASK [--job NAME]
+
+    $ fargate-td cron migrate -p app1/development -t task1 --job job1`,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	SetCronOptions(c, ftr, &r.CronRunner)
+	c.Flags().BoolVar(&r.DeleteRule, "delete-rule", false, "delete the rule instead of disabling it")
+	c.Flags().StringVar(&r.Timezone, "timezone", "", "time zone of the schedule expression (default: timezone of the cron job in config, or UTC which rules are evaluated in)")
+	r.Command = c
+	return c
+}
+
+type CronMigrateRunner struct {
+	CronRunner
+	DeleteRule bool
+	Timezone   string
+}
+
+func (r *CronMigrateRunner) runE(c *cobra.Command, args []string) error {
+	ctx := context.Background()
+	cronJobs, err := r.CronJobs()
+	if err != nil {
+		return err
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+	cweSvc := cloudwatchevents.NewFromConfig(cfg)
+	schSvc := scheduler.NewFromConfig(cfg)
+
+	var failedCronJobList []string
+	for _, job := range cronJobs {
+		if job.ScheduleGroup == "" {
+			job.ScheduleGroup = "default"
+		}
+		if err := r.migrate(ctx, cweSvc, schSvc, job); err != nil {
+			fmt.Printf("Failed to migrate [cluster: %s, cronJob: %s]: %s\n", job.Cluster, job.CronJob, err)
+			failedCronJobList = append(failedCronJobList, "[cluster: "+job.Cluster+", cron job: "+job.CronJob+"]")
+			continue
+		}
+		fmt.Printf("Migrated [cluster: %s, cronJob: %s]\n", job.Cluster, job.CronJob)
+		if !job.UsesScheduler() {
+			fmt.Printf("Set `scheduler: %s` to cron job %s in config.yml\n", config.SchedulerEventBridgeScheduler, job.CronJob)
+		}
+	}
+	if len(failedCronJobList) != 0 {
+		return fmt.Errorf("failed to migrate cron jobs: %s", strings.Join(failedCronJobList, ", "))
+	}
+	return nil
+}
+
+func (r *CronMigrateRunner) migrate(ctx context.Context, cweSvc *cloudwatchevents.Client, schSvc *scheduler.Client, job config.CronJobTaskConfig) error {
+	rule, err := cweSvc.DescribeRule(ctx, &cloudwatchevents.DescribeRuleInput{
+		Name: &job.CronJob,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get rule: %w", err)
+	}
+	targets, err := cweSvc.ListTargetsByRule(ctx, &cloudwatchevents.ListTargetsByRuleInput{
+		Rule: rule.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get targets: %w", err)
+	}
+	var ruleTarget *cwetypes.Target
+	for i, target := range targets.Targets {
+		if target.EcsParameters != nil {
+			ruleTarget = &targets.Targets[i]
+			break
+		}
+	}
+	if ruleTarget == nil {
+		return errors.New("rule does not have ECS target")
+	}
+	schedule, err := getSchedule(ctx, schSvc, job)
+	if err != nil {
+		return fmt.Errorf("failed to get schedule: %w", err)
+	}
+	if schedule != nil {
+		return fmt.Errorf("schedule %s already exists in group %s", job.CronJob, job.ScheduleGroup)
+	}
+
+	state := schtypes.ScheduleStateEnabled
+	if rule.State == cwetypes.RuleStateDisabled {
+		state = schtypes.ScheduleStateDisabled
+	}
+	// The target of the rule is copied as it is, except for the role which must trust the scheduler
+	target := scheduleTargetFromRuleTarget(ruleTarget)
+	if job.RoleArn != "" {
+		target.RoleArn = aws.String(job.RoleArn)
+	}
+	// Keep the schedule expression of the rule
+	job.Cron = aws.ToString(rule.ScheduleExpression)
+	if r.Timezone != "" {
+		job.Timezone = r.Timezone
+	}
+	in := newScheduleInput(job, &scheduler.GetScheduleOutput{
+		Description: rule.Description,
+		State:       state,
+	}, target)
+	_, err = schSvc.CreateSchedule(ctx, &scheduler.CreateScheduleInput{
+		Name:                       in.Name,
+		GroupName:                  in.GroupName,
+		Description:                in.Description,
+		ScheduleExpression:         in.ScheduleExpression,
+		ScheduleExpressionTimezone: in.ScheduleExpressionTimezone,
+		StartDate:                  in.StartDate,
+		EndDate:                    in.EndDate,
+		FlexibleTimeWindow:         in.FlexibleTimeWindow,
+		State:                      in.State,
+		Target:                     in.Target,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create schedule: %w", err)
+	}
+
+	if !r.DeleteRule {
+		if _, err := cweSvc.DisableRule(ctx, &cloudwatchevents.DisableRuleInput{Name: rule.Name}); err != nil {
+			return fmt.Errorf("failed to disable rule: %w", err)
+		}
+		return nil
+	}
+	var ids []string
+	for _, target := range targets.Targets {
+		ids = append(ids, *target.Id)
+	}
+	if _, err := cweSvc.RemoveTargets(ctx, &cloudwatchevents.RemoveTargetsInput{Rule: rule.Name, Ids: ids}); err != nil {
+		return fmt.Errorf("failed to remove targets: %w", err)
+	}
+	if _, err := cweSvc.DeleteRule(ctx, &cloudwatchevents.DeleteRuleInput{Name: rule.Name}); err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
+	}
+	return nil
+}
+
+func scheduleTargetFromRuleTarget(t *cwetypes.Target) *schtypes.Target {
+	target := &schtypes.Target{
+		Arn:     t.Arn,
+		RoleArn: t.RoleArn,
+		Input:   t.Input,
+	}
+	if t.DeadLetterConfig != nil {
+		target.DeadLetterConfig = &schtypes.DeadLetterConfig{
+			Arn: t.DeadLetterConfig.Arn,
+		}
+	}
+	if t.RetryPolicy != nil {
+		target.RetryPolicy = &schtypes.RetryPolicy{
+			MaximumEventAgeInSeconds: t.RetryPolicy.MaximumEventAgeInSeconds,
+			MaximumRetryAttempts:     t.RetryPolicy.MaximumRetryAttempts,
+		}
+	}
+	p := t.EcsParameters
+	ecsParams := &schtypes.EcsParameters{
+		TaskDefinitionArn:    p.TaskDefinitionArn,
+		EnableECSManagedTags: aws.Bool(p.EnableECSManagedTags),
+		EnableExecuteCommand: aws.Bool(p.EnableExecuteCommand),
+		Group:                p.Group,
+		LaunchType:           schtypes.LaunchType(p.LaunchType),
+		PlatformVersion:      p.PlatformVersion,
+		PropagateTags:        schtypes.PropagateTags(p.PropagateTags),
+		ReferenceId:          p.ReferenceId,
+		TaskCount:            p.TaskCount,
+	}
+	for _, cp := range p.CapacityProviderStrategy {
+		ecsParams.CapacityProviderStrategy = append(ecsParams.CapacityProviderStrategy, schtypes.CapacityProviderStrategyItem{
+			CapacityProvider: cp.CapacityProvider,
+			Base:             cp.Base,
+			Weight:           cp.Weight,
+		})
+	}
+	for _, pc := range p.PlacementConstraints {
+		ecsParams.PlacementConstraints = append(ecsParams.PlacementConstraints, schtypes.PlacementConstraint{
+			Expression: pc.Expression,
+			Type:       schtypes.PlacementConstraintType(pc.Type),
+		})
+	}
+	for _, ps := range p.PlacementStrategy {
+		ecsParams.PlacementStrategy = append(ecsParams.PlacementStrategy, schtypes.PlacementStrategy{
+			Field: ps.Field,
+			Type:  schtypes.PlacementStrategyType(ps.Type),
+		})
+	}
+	if p.NetworkConfiguration != nil && p.NetworkConfiguration.AwsvpcConfiguration != nil {
+		vpc := p.NetworkConfiguration.AwsvpcConfiguration
+		ecsParams.NetworkConfiguration = &schtypes.NetworkConfiguration{
+			AwsvpcConfiguration: &schtypes.AwsVpcConfiguration{
+				Subnets:        vpc.Subnets,
+				SecurityGroups: vpc.SecurityGroups,
+				AssignPublicIp: schtypes.AssignPublicIp(vpc.AssignPublicIp),
+			},
+		}
+	}
+	for _, tag := range p.Tags {
+		ecsParams.Tags = append(ecsParams.Tags, map[string]string{
+			"key":   aws.ToString(tag.Key),
+			"value": aws.ToString(tag.Value),
+		})
+	}
+	target.EcsParameters = ecsParams
+	return target
+}
+
+// ruleTargetFromScheduleTarget returns the ECS target of the schedule as a rule target, which is the reverse of scheduleTargetFromRuleTarget.
+func ruleTargetFromScheduleTarget(t *schtypes.Target) cwetypes.Target {
+	target := cwetypes.Target{
+		Arn:     t.Arn,
+		RoleArn: t.RoleArn,
+		Input:   t.Input,
+	}
+	if t.DeadLetterConfig != nil {
+		target.DeadLetterConfig = &cwetypes.DeadLetterConfig{
+			Arn: t.DeadLetterConfig.Arn,
+		}
+	}
+	if t.RetryPolicy != nil {
+		target.RetryPolicy = &cwetypes.RetryPolicy{
+			MaximumEventAgeInSeconds: t.RetryPolicy.MaximumEventAgeInSeconds,
+			MaximumRetryAttempts:     t.RetryPolicy.MaximumRetryAttempts,
+		}
+	}
+	p := t.EcsParameters
+	if p == nil {
+		return target
+	}
+	ecsParams := &cwetypes.EcsParameters{
+		TaskDefinitionArn:    p.TaskDefinitionArn,
+		EnableECSManagedTags: aws.ToBool(p.EnableECSManagedTags),
+		EnableExecuteCommand: aws.ToBool(p.EnableExecuteCommand),
+		Group:                p.Group,
+		LaunchType:           cwetypes.LaunchType(p.LaunchType),
+		PlatformVersion:      p.PlatformVersion,
+		PropagateTags:        cwetypes.PropagateTags(p.PropagateTags),
+		ReferenceId:          p.ReferenceId,
+		TaskCount:            p.TaskCount,
+	}
+	for _, cp := range p.CapacityProviderStrategy {
+		ecsParams.CapacityProviderStrategy = append(ecsParams.CapacityProviderStrategy, cwetypes.CapacityProviderStrategyItem{
+			CapacityProvider: cp.CapacityProvider,
+			Base:             cp.Base,
+			Weight:           cp.Weight,
+		})
+	}
+	for _, pc := range p.PlacementConstraints {
+		ecsParams.PlacementConstraints = append(ecsParams.PlacementConstraints, cwetypes.PlacementConstraint{
+			Expression: pc.Expression,
+			Type:       cwetypes.PlacementConstraintType(pc.Type),
+		})
+	}
+	for _, ps := range p.PlacementStrategy {
+		ecsParams.PlacementStrategy = append(ecsParams.PlacementStrategy, cwetypes.PlacementStrategy{
+			Field: ps.Field,
+			Type:  cwetypes.PlacementStrategyType(ps.Type),
+		})
+	}
+	if p.NetworkConfiguration != nil && p.NetworkConfiguration.AwsvpcConfiguration != nil {
+		vpc := p.NetworkConfiguration.AwsvpcConfiguration
+		ecsParams.NetworkConfiguration = &cwetypes.NetworkConfiguration{
+			AwsvpcConfiguration: &cwetypes.AwsVpcConfiguration{
+				Subnets:        vpc.Subnets,
+				SecurityGroups: vpc.SecurityGroups,
+				AssignPublicIp: cwetypes.AssignPublicIp(vpc.AssignPublicIp),
+			},
+		}
+	}
+	for _, tag := range p.Tags {
+		ecsParams.Tags = append(ecsParams.Tags, cwetypes.Tag{
+			Key:   aws.String(tag["key"]),
+			Value: aws.String(tag["value"]),
+		})
+	}
+	target.EcsParameters = ecsParams
+	return target
+}
@@ -1,24 +1,37 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchevents"
+	cwetypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchevents/types"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
 	"github.com/aws/smithy-go/document"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
-	"github.com/logrusorgru/aurora/v3"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 
 	"github.com/kazz187/fargate-td/internal/config"
+	"github.com/kazz187/fargate-td/internal/lock"
+	"github.com/kazz187/fargate-td/internal/ratelimit"
+	"github.com/kazz187/fargate-td/internal/redact"
+	"github.com/kazz187/fargate-td/internal/report"
+	"github.com/kazz187/fargate-td/internal/taskdef"
 )
 
 func DeployCommand(ftr *FargateTdRunner) *cobra.Command {
@@ -40,13 +53,22 @@ Run 'fargate-td deploy -p PATH -t TASK -v"Key=Value -n TASK_DEFINITION_NAME"
 	}
 	SetGenerateOptions(c, ftr, &r.GenerateRunner)
 	c.Flags().BoolVar(&r.TdOnly, "td-only", false, "deploy task definition only")
+	c.Flags().BoolVar(&r.Plan, "plan", false, "show the diff and write the report without registering task definition and updating services and cron jobs")
+	SetReportOptions(c, &r.ReportOptions)
+	SetRedactionOptions(c, &r.GenerateRunner)
+	SetConfirmOptions(c, &r.ConfirmOptions)
+	c.Flags().StringVar(&r.LockOwner, "lock-owner", defaultLockOwner(), "owner description of the deploy lock")
 	r.Command = c
 	return c
 }
 
 type DeployRunner struct {
 	GenerateRunner
-	TdOnly bool
+	ReportOptions
+	ConfirmOptions
+	TdOnly    bool
+	Plan      bool
+	LockOwner string
 }
 
 func (r *DeployRunner) preRunE(c *cobra.Command, args []string) error {
@@ -54,102 +76,176 @@ func (r *DeployRunner) preRunE(c *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	if r.Plan && r.TdOnly {
+		return errors.New("--td-only is not supported with --plan")
+	}
+	return r.ReportOptions.validate()
 }
 
 func (r *DeployRunner) runE(c *cobra.Command, args []string) error {
 	ctx := context.Background()
 	// Load deploy config
-	deployConf := config.NewDeployConfig()
-	searchDeployConfPath := filepath.Clean(
-		strings.Join(
-			[]string{
-				r.ProjectRootPath,
-				taskPath,
-				r.TargetTaskPath,
-			},
-			"/",
-		),
-	)
-	err := deployConf.Load(searchDeployConfPath)
+	deployConf, err := loadDeployConfig(r.ProjectRootPath, r.TargetTaskPath)
 	if err != nil {
 		return err
 	}
 
 	// Generate task definition
-	taskStr, err := r.GenerateRunner.GenerateTaskDefinition()
+	in, err := r.GenerateRunner.GenerateRegisterTaskDefinitionInput()
 	if err != nil {
 		return err
 	}
-
-	// Replace keys of task yaml to lowercase
-	taskYaml := map[string]interface{}{}
-	err = yaml.Unmarshal([]byte(taskStr), &taskYaml)
+	redactor, err := r.GenerateRunner.Redactor()
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal task yaml")
+		return err
 	}
-	replacedTaskYaml := replaceLowerCaseKey(taskYaml)
-	inStr, err := yaml.Marshal(replacedTaskYaml)
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal task yaml")
+		return fmt.Errorf("failed to load aws config: %w", err)
 	}
 
-	// Load to struct RegisterTaskDefinitionInput
-	in := &ecs.RegisterTaskDefinitionInput{}
-	err = yaml.Unmarshal(inStr, in)
-	if err != nil {
-		return fmt.Errorf("failed to load task definition yaml file: %w", err)
+	// Lock the task of the target path until the deploy is finished, a plan changes nothing to be locked
+	var locker lock.Locker
+	if !r.Plan {
+		locker, err = newLocker(ctx, cfg, r.ProjectRootPath, deployConf, r.TaskName)
+		if err != nil {
+			return fmt.Errorf("failed to create deploy lock: %w", err)
+		}
 	}
-	cfg, err := awsconfig.LoadDefaultConfig(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to load aws config: %w", err)
+	var l *lock.Lock
+	if locker != nil {
+		l, err = locker.Acquire(ctx, lockKey(r.TargetTaskPath, r.TaskName), r.LockOwner, deployConf.Lock().TTL)
+		if err != nil {
+			return fmt.Errorf("failed to acquire deploy lock: %w", err)
+		}
+		fmt.Printf("Acquired lock [key: %s, owner: %s]\n", l.Key, l.Owner)
+		defer func() {
+			if err := locker.Release(ctx, l); err != nil {
+				logrus.Warnf("failed to release deploy lock: %s", err)
+				return
+			}
+			fmt.Printf("Released lock [key: %s]\n", l.Key)
+		}()
 	}
 
-	//
-	ecsSvc := ecs.NewFromConfig(cfg)
+	// Task definitions of all services are described, so the calls are limited not to be throttled in large clusters
+	limiter := ratelimit.New(ratelimit.DefaultRate)
+	ecsSvc := ecs.NewFromConfig(cfg, func(o *ecs.Options) {
+		o.APIOptions = append(o.APIOptions, limiter.AddMiddleware)
+	})
 	cweSvc := cloudwatchevents.NewFromConfig(cfg)
-	tdRes, err := ecsSvc.RegisterTaskDefinition(ctx, in)
-	if err != nil {
-		return fmt.Errorf("failed to register task definition: %w", err)
+	schSvc := scheduler.NewFromConfig(cfg)
+	var newTd *types.TaskDefinition
+	var rep *report.Report
+	if r.Plan {
+		newTd, err = planTaskDefinition(ctx, ecsSvc, in)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Task definition is not registered in plan [%s]\n", *newTd.TaskDefinitionArn)
+		rep = report.New(fmt.Sprintf("Plan of deploy %s (%s)", r.TaskName, taskdef.Revision(*newTd.TaskDefinitionArn)))
+	} else {
+		tdRes, err := ecsSvc.RegisterTaskDefinition(ctx, in)
+		if err != nil {
+			return fmt.Errorf("failed to register task definition: %w", err)
+		}
+		newTd = tdRes.TaskDefinition
+		rep = report.New(fmt.Sprintf("Deploy %s (%s)", r.TaskName, taskdef.Revision(*newTd.TaskDefinitionArn)))
 	}
 	servicesMap := deployConf.GetServicesMapGroupByCluster(r.TaskName)
-	serviceDiffMap, err := diffServiceTaskDefinition(ctx, ecsSvc, servicesMap, tdRes.TaskDefinition)
+	serviceChangedMap, err := diffServiceTaskDefinition(ctx, ecsSvc, servicesMap, newTd, rep, redactor)
 	if err != nil {
 		return fmt.Errorf("failed to compare task definitions: %w", err)
 	}
 	cronJobs := deployConf.GetCronJobTaskConfigs(r.TaskName)
-	cronJobDiffMap, err := diffCronJobTaskDefinition(ctx, ecsSvc, cweSvc, cronJobs, tdRes.TaskDefinition)
+	cronJobPlans, err := planCronJobs(ctx, ecsSvc, cweSvc, schSvc, cronJobs, newTd, rep, redactor)
+	if err != nil {
+		return fmt.Errorf("failed to compare task definitions: %w", err)
+	}
+	if err := r.ReportOptions.write(rep); err != nil {
+		return err
+	}
+	if r.Plan {
+		return nil
+	}
 	if !r.TdOnly {
+		if err := r.ConfirmOptions.confirm(c, rep, deployConf); err != nil {
+			return err
+		}
+		// The lock may expire while waiting for confirmation
+		if err := verifyLock(ctx, locker, l); err != nil {
+			return err
+		}
 		serviceTaskConfig := deployConf.GetServiceTaskConfigs(r.TaskName)
-		if err := updateService(ctx, ecsSvc, serviceTaskConfig, serviceDiffMap, *tdRes.TaskDefinition.TaskDefinitionArn); err != nil {
+		if err := updateService(ctx, ecsSvc, serviceTaskConfig, serviceChangedMap, *newTd.TaskDefinitionArn); err != nil {
 			return err
 		}
 
-		cronJobTaskConfig := deployConf.GetCronJobTaskConfigs(r.TaskName)
-		if err := updateCronJob(ctx, cweSvc, cronJobTaskConfig, cronJobDiffMap, *tdRes.TaskDefinition.TaskDefinitionArn); err != nil {
+		if err := verifyLock(ctx, locker, l); err != nil {
+			return err
+		}
+		if err := updateCronJob(ctx, cweSvc, schSvc, cronJobPlans); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func diffServiceTaskDefinition(ctx context.Context, svc *ecs.Client, servicesMap map[string][]string, newTd *types.TaskDefinition) (map[string]string, error) {
-	diffMap := map[string]string{}
+// planTaskDefinition returns the rendered task definition as the next revision of its family, without registering it.
+// The revision is the one after the latest active revision, which may differ from the revision which is registered by the deploy.
+func planTaskDefinition(ctx context.Context, ecsSvc *ecs.Client, in *ecs.RegisterTaskDefinitionInput) (*types.TaskDefinition, error) {
+	family := aws.ToString(in.Family)
+	res, err := ecsSvc.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: &family,
+	})
+	if err != nil {
+		var clientErr *types.ClientException
+		if !errors.As(err, &clientErr) {
+			return nil, fmt.Errorf("failed to get latest task definition of family %s: %w", family, err)
+		}
+		// The family is not registered yet
+		return taskdef.ToTaskDefinition(in, family+":1"), nil
+	}
+	latest := res.TaskDefinition
+	tdArn := strings.TrimSuffix(*latest.TaskDefinitionArn, fmt.Sprintf(":%d", latest.Revision))
+	return taskdef.ToTaskDefinition(in, fmt.Sprintf("%s:%d", tdArn, latest.Revision+1)), nil
+}
+
+func loadDeployConfig(projectRootPath, targetTaskPath string) (*config.DeployConfig, error) {
+	deployConf := config.NewDeployConfig()
+	searchDeployConfPath := filepath.Clean(
+		strings.Join(
+			[]string{
+				projectRootPath,
+				taskPath,
+				targetTaskPath,
+			},
+			"/",
+		),
+	)
+	if err := deployConf.Load(searchDeployConfPath); err != nil {
+		return nil, err
+	}
+	return deployConf, nil
+}
+
+func diffServiceTaskDefinition(ctx context.Context, svc *ecs.Client, servicesMap map[string][]string, newTd *types.TaskDefinition, rep *report.Report, redactor *redact.Redactor) (map[string]bool, error) {
+	changedMap := map[string]bool{}
 
 	for cluster, services := range servicesMap {
-		svcRes, err := svc.DescribeServices(ctx, &ecs.DescribeServicesInput{
-			Cluster:  &cluster,
-			Services: services,
-		})
-		if err != nil {
-			return nil, err
-		}
-		if svcRes == nil {
-			return nil, fmt.Errorf("service is not found in cluster %s", cluster)
-		}
 		svcToTd := map[string]string{}
-		for _, s := range svcRes.Services {
-			svcToTd[*s.ServiceName] = *s.TaskDefinition
+		for i := 0; i < len(services); i += maxDescribeServices {
+			end := min(i+maxDescribeServices, len(services))
+			svcRes, err := svc.DescribeServices(ctx, &ecs.DescribeServicesInput{
+				Cluster:  &cluster,
+				Services: services[i:end],
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range svcRes.Services {
+				svcToTd[*s.ServiceName] = *s.TaskDefinition
+			}
 		}
 
 		for _, s := range services {
@@ -158,76 +254,209 @@ func diffServiceTaskDefinition(ctx context.Context, svc *ecs.Client, servicesMap
 			if !ok {
 				return nil, fmt.Errorf("service %s is not found in cluster %s", s, cluster)
 			}
-			currentTdRes, err := svc.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
-				TaskDefinition: &td,
-			})
+			changed, err := diffTaskDefinition(ctx, svc, td, newTd, rep.Add(report.KindService, cluster, s), redactor)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get current task definition: %w", err)
-			}
-			currentTdRes.TaskDefinition.Revision = newTd.Revision
-			currentTdRes.TaskDefinition.TaskDefinitionArn = newTd.TaskDefinitionArn
-			diff := cmp.Diff(currentTdRes.TaskDefinition, newTd, cmpopts.IgnoreTypes(document.NoSerde{}), cmpopts.IgnoreFields(*newTd, "RegisteredAt"))
-			diffMap[s] = diff
-			if diff == "" {
-				fmt.Println("Already up-to-date")
-			} else {
-				fmt.Println("```")
-				displayColorDiff(diff)
-				fmt.Println("```")
+				return nil, err
 			}
+			changedMap[s] = changed
 		}
 	}
-	return diffMap, nil
+	return changedMap, nil
 }
 
-func diffCronJobTaskDefinition(ctx context.Context, ecsSvc *ecs.Client, cweSvc *cloudwatchevents.Client, cronJobs []config.CronJobTaskConfig, newTd *types.TaskDefinition) (map[string]string, error) {
-	diffMap := map[string]string{}
+// cronJobPlan is the update of a cron job to its config.
+// It is planned before confirmation, so that changes of rules, targets and schedules are confirmed as well as task definitions.
+type cronJobPlan struct {
+	conf config.CronJobTaskConfig
+	// rule is the current rule, or nil if the rule is created or the cron job uses a schedule
+	rule           *cloudwatchevents.DescribeRuleOutput
+	putRule        *cloudwatchevents.PutRuleInput
+	ruleState      cwetypes.RuleState
+	putTargets     []cwetypes.Target
+	createSchedule *scheduler.CreateScheduleInput
+	updateSchedule *scheduler.UpdateScheduleInput
+}
 
+// planCronJobs prints and returns the updates of the cron jobs to the task definition newTd, and adds them to the report.
+func planCronJobs(ctx context.Context, ecsSvc *ecs.Client, cweSvc *cloudwatchevents.Client, schSvc *scheduler.Client, cronJobs []config.CronJobTaskConfig, newTd *types.TaskDefinition, rep *report.Report, redactor *redact.Redactor) ([]*cronJobPlan, error) {
+	var plans []*cronJobPlan
+	clusterArns := map[string]string{}
 	for _, job := range cronJobs {
-		fmt.Printf("Diff [cluster: %s, cronJob: %s]\n", job.Cluster, job.CronJob)
-		rule, err := cweSvc.DescribeRule(ctx, &cloudwatchevents.DescribeRuleInput{
-			Name: &job.CronJob,
-		})
+		target := rep.Add(report.KindCronJob, job.Cluster, job.CronJob)
+		clusterArn, ok := clusterArns[job.Cluster]
+		if !ok {
+			arn, err := describeClusterArn(ctx, ecsSvc, job.Cluster)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get cluster: %w", err)
+			}
+			clusterArn = arn
+			clusterArns[job.Cluster] = arn
+		}
+		var plan *cronJobPlan
+		var err error
+		if job.UsesScheduler() {
+			plan, err = planSchedule(ctx, ecsSvc, schSvc, job, clusterArn, newTd, target, redactor)
+		} else {
+			plan, err = planRule(ctx, ecsSvc, cweSvc, job, clusterArn, newTd, target, redactor)
+		}
 		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// planRule prints and returns the update of the rule and its targets of the cron job, and adds it to the report target.
+func planRule(ctx context.Context, ecsSvc *ecs.Client, cweSvc *cloudwatchevents.Client, job config.CronJobTaskConfig, clusterArn string, newTd *types.TaskDefinition, target *report.Target, redactor *redact.Redactor) (*cronJobPlan, error) {
+	fmt.Printf("Diff [cluster: %s, cronJob: %s]\n", job.Cluster, job.CronJob)
+	plan := &cronJobPlan{conf: job}
+	newTdArn := aws.ToString(newTd.TaskDefinitionArn)
+	rule, err := cweSvc.DescribeRule(ctx, &cloudwatchevents.DescribeRuleInput{
+		Name: &job.CronJob,
+	})
+	if err != nil {
+		var notFound *cwetypes.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
 			return nil, fmt.Errorf("failed to get rule: %w", err)
 		}
-		targets, err := cweSvc.ListTargetsByRule(ctx, &cloudwatchevents.ListTargetsByRuleInput{
-			Rule: rule.Name,
+		newTarget, err := buildCronJobTarget(job, cwetypes.Target{Id: &job.CronJob}, clusterArn, newTdArn)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println("Rule is not found, it will be created")
+		target.NewTaskDefinitionArn = newTdArn
+		target.AddNote(report.StatusCreated, "rule is not found, it will be created", "")
+		plan.putRule = &cloudwatchevents.PutRuleInput{
+			Name:               &job.CronJob,
+			ScheduleExpression: &job.Cron,
+			State:              ruleState(job.State, cwetypes.RuleStateEnabled),
+		}
+		plan.putTargets = []cwetypes.Target{newTarget}
+		return plan, nil
+	}
+	plan.rule = rule
+
+	state := ruleState(job.State, rule.State)
+	if expr := aws.ToString(rule.ScheduleExpression); expr != job.Cron {
+		message := fmt.Sprintf("schedule %s differs from %s in config, it will be updated", expr, job.Cron)
+		fmt.Println(upperFirst(message))
+		target.AddNote(report.StatusChanged, message, "")
+		plan.putRule = &cloudwatchevents.PutRuleInput{
+			Name:               &job.CronJob,
+			ScheduleExpression: &job.Cron,
+			// PutRule enables the rule if the state is not specified
+			State: state,
+		}
+	} else if state != rule.State {
+		plan.ruleState = state
+	}
+	if state != rule.State {
+		message := fmt.Sprintf("state %s differs from %s in config, it will be updated", rule.State, state)
+		fmt.Println(upperFirst(message))
+		target.AddNote(report.StatusChanged, message, "")
+	}
+
+	targets, err := cweSvc.ListTargetsByRule(ctx, &cloudwatchevents.ListTargetsByRuleInput{
+		Rule: rule.Name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get targets: %w", err)
+	}
+	var currentTargets []cwetypes.Target
+	tdChanged := false
+	for _, t := range targets.Targets {
+		if t.EcsParameters == nil {
+			continue
+		}
+		currentTargets = append(currentTargets, t)
+		changed, err := diffTaskDefinition(ctx, ecsSvc, *t.EcsParameters.TaskDefinitionArn, newTd, target, redactor)
+		if err != nil {
+			return nil, err
+		}
+		tdChanged = tdChanged || changed
+	}
+	if len(currentTargets) == 0 {
+		target.NewTaskDefinitionArn = newTdArn
+		currentTargets = append(currentTargets, cwetypes.Target{
+			Id: &job.CronJob,
 		})
+	}
+	for _, t := range currentTargets {
+		tdArn := newTdArn
+		if t.EcsParameters != nil && !tdChanged {
+			// Keep the current revision if the task definition is not changed
+			tdArn = *t.EcsParameters.TaskDefinitionArn
+		}
+		newTarget, err := buildCronJobTarget(job, t, clusterArn, tdArn)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get targets: %w", err)
+			return nil, err
 		}
-		for _, target := range targets.Targets {
-			if target.EcsParameters == nil {
-				continue
-			}
-			tdArn := target.EcsParameters.TaskDefinitionArn
-			currentTd, err := ecsSvc.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
-				TaskDefinition: tdArn,
-			})
-			if err != nil {
-				return nil, fmt.Errorf("failed to get task definition: %w", err)
-			}
-			currentTd.TaskDefinition.Revision = newTd.Revision
-			currentTd.TaskDefinition.TaskDefinitionArn = newTd.TaskDefinitionArn
-			diff := cmp.Diff(currentTd.TaskDefinition, newTd, cmpopts.IgnoreTypes(document.NoSerde{}), cmpopts.IgnoreFields(*newTd, "RegisteredAt"))
-			diffMap[*target.Id] = diff
-			if diff == "" {
-				fmt.Println("Already up-to-date")
-			} else {
-				fmt.Println("```")
-				displayColorDiff(diff)
-				fmt.Println("```")
-			}
+		if cmp.Equal(t, newTarget, cronJobComparer(nil)) {
+			continue
 		}
+		message := fmt.Sprintf("target %s differs from config, it will be updated", aws.ToString(newTarget.Id))
+		diff := cmp.Diff(t, newTarget, cronJobComparer(redactor))
+		fmt.Println(upperFirst(message))
+		fmt.Println("```")
+		displayColorDiff(diff)
+		fmt.Println("```")
+		target.AddNote(report.StatusChanged, message, diff)
+		plan.putTargets = append(plan.putTargets, newTarget)
+	}
+	return plan, nil
+}
+
+// upperFirst returns the message whose first letter is upper case, to print a note of the report as a sentence.
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// diffTaskDefinition prints the diff between the task definition tdArn and newTd, adds it to the report target and reports whether it is changed.
+// Sensitive values are masked by the redactor.
+func diffTaskDefinition(ctx context.Context, svc *ecs.Client, tdArn string, newTd *types.TaskDefinition, target *report.Target, redactor *redact.Redactor) (bool, error) {
+	currentTdRes, err := svc.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: &tdArn,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get current task definition %s: %w", tdArn, err)
+	}
+	// Fields which are set by ECS on registration, such as revision and status, are not compared
+	change, err := taskdef.Compare(
+		redactor.TaskDefinition(taskdef.FromTaskDefinition(currentTdRes.TaskDefinition, nil)),
+		redactor.TaskDefinition(taskdef.FromTaskDefinition(newTd, nil)),
+		taskdef.Revision(tdArn),
+		taskdef.Revision(aws.ToString(newTd.TaskDefinitionArn)),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to diff task definition %s: %w", tdArn, err)
+	}
+	target.CurrentTaskDefinitionArn = tdArn
+	if change == nil {
+		fmt.Println("Already up-to-date")
+		return false, nil
+	}
+	target.NewTaskDefinitionArn = aws.ToString(newTd.TaskDefinitionArn)
+	target.AddChange(change)
+	displayTaskDefinitionChange(change)
+	return true, nil
+}
+
+// displayTaskDefinitionChange prints the summary and the diff of the change.
+func displayTaskDefinitionChange(change *taskdef.Change) {
+	for _, s := range change.Summary {
+		fmt.Printf("  %s\n", s)
 	}
-	return diffMap, nil
+	fmt.Println("```diff")
+	displayColorDiff(change.Diff)
+	fmt.Println("```")
 }
 
-func updateService(ctx context.Context, svc *ecs.Client, taskConfList []config.ServiceTaskConfig, diffMap map[string]string, tdArn string) error {
+func updateService(ctx context.Context, svc *ecs.Client, taskConfList []config.ServiceTaskConfig, changedMap map[string]bool, tdArn string) error {
 	var failedServiceList []string
 	for _, taskConf := range taskConfList {
-		if diffMap[taskConf.Service] == "" {
+		if !changedMap[taskConf.Service] {
 			fmt.Printf("Skip update service [cluster: %s, service: %s]\n", taskConf.Cluster, taskConf.Service)
 			continue
 		}
@@ -248,70 +477,424 @@ func updateService(ctx context.Context, svc *ecs.Client, taskConfList []config.S
 	return nil
 }
 
-func updateCronJob(ctx context.Context, cweSvc *cloudwatchevents.Client, taskConfList []config.CronJobTaskConfig, diffMap map[string]string, tdArn string) error {
+func updateCronJob(ctx context.Context, cweSvc *cloudwatchevents.Client, schSvc *scheduler.Client, plans []*cronJobPlan) error {
 	var failedCronJobList []string
-	for _, taskConf := range taskConfList {
-		ruleInput := cloudwatchevents.DescribeRuleInput{
-			Name:         &taskConf.CronJob,
-			EventBusName: nil,
-		}
-		rule, err := cweSvc.DescribeRule(ctx, &ruleInput)
-		if err != nil {
-			logrus.Errorf("failed to get cron rule: %s", err)
-			failedCronJobList = append(failedCronJobList, "[cluster: "+taskConf.Cluster+", cron job: "+taskConf.CronJob+"]")
-			continue
+	for _, plan := range plans {
+		if err := plan.apply(ctx, cweSvc, schSvc); err != nil {
+			logrus.Errorf("failed to update cron job: %s", err)
+			failedCronJobList = append(failedCronJobList, "[cluster: "+plan.conf.Cluster+", cron job: "+plan.conf.CronJob+"]")
 		}
-		if *rule.ScheduleExpression != taskConf.Cron {
-			cronJobInput := &cloudwatchevents.PutRuleInput{
-				Name:               &taskConf.CronJob,
-				ScheduleExpression: &taskConf.Cron,
+	}
+	if len(failedCronJobList) != 0 {
+		return fmt.Errorf("failed to update cron jobs: %s", strings.Join(failedCronJobList, ", "))
+	}
+	return nil
+}
+
+// apply updates the rule and the targets, or the schedule, of the cron job as planned.
+func (p *cronJobPlan) apply(ctx context.Context, cweSvc *cloudwatchevents.Client, schSvc *scheduler.Client) error {
+	job := p.conf
+	if job.UsesScheduler() {
+		switch {
+		case p.createSchedule != nil:
+			fmt.Printf("Create schedule [cluster: %s, cronJob: %s, cron: %s]\n", job.Cluster, job.CronJob, job.Cron)
+			if _, err := schSvc.CreateSchedule(ctx, p.createSchedule); err != nil {
+				return fmt.Errorf("failed to create schedule: %w", err)
 			}
-			fmt.Printf("Update cron schedule [%s -> %s]\n", *rule.ScheduleExpression, taskConf.Cron)
-			if _, err := cweSvc.PutRule(ctx, cronJobInput); err != nil {
-				logrus.Errorf("failed to update cron job: %s", err)
-				failedCronJobList = append(failedCronJobList, "[cluster: "+taskConf.Cluster+", cron job: "+taskConf.CronJob+", cron: "+taskConf.Cron+"]")
+		case p.updateSchedule != nil:
+			fmt.Printf("Update schedule [cluster: %s, cronJob: %s, cron: %s]\n", job.Cluster, job.CronJob, job.Cron)
+			if _, err := schSvc.UpdateSchedule(ctx, p.updateSchedule); err != nil {
+				return fmt.Errorf("failed to update schedule: %w", err)
 			}
+		default:
+			fmt.Printf("Skip update schedule [cluster: %s, cronJob: %s]\n", job.Cluster, job.CronJob)
 		}
+		return nil
+	}
 
-		if diffMap[taskConf.CronJob] == "" {
-			fmt.Printf("Skip update cron job [cluster: %s, cronJob: %s]\n", taskConf.Cluster, taskConf.CronJob)
-			continue
+	if p.putRule == nil && p.ruleState == "" && len(p.putTargets) == 0 {
+		fmt.Printf("Skip update cron job [cluster: %s, cronJob: %s]\n", job.Cluster, job.CronJob)
+		return nil
+	}
+	if p.putRule != nil {
+		if p.rule == nil {
+			fmt.Printf("Create cron rule [cluster: %s, cronJob: %s, cron: %s]\n", job.Cluster, job.CronJob, job.Cron)
+		} else {
+			fmt.Printf("Update cron schedule [cluster: %s, cronJob: %s, cron: %s -> %s]\n", job.Cluster, job.CronJob, aws.ToString(p.rule.ScheduleExpression), job.Cron)
+		}
+		if _, err := cweSvc.PutRule(ctx, p.putRule); err != nil {
+			return fmt.Errorf("failed to put cron rule: %w", err)
+		}
+	} else if p.ruleState != "" {
+		fmt.Printf("Update cron state [cluster: %s, cronJob: %s, state: %s -> %s]\n", job.Cluster, job.CronJob, p.rule.State, p.ruleState)
+		if err := setRuleState(ctx, cweSvc, job.CronJob, p.ruleState); err != nil {
+			return fmt.Errorf("failed to update cron state: %w", err)
 		}
+	}
+	if len(p.putTargets) == 0 {
+		return nil
+	}
+	for _, t := range p.putTargets {
+		fmt.Printf("Update cron job [cluster: %s, cronJob: %s, target: %s]\n", job.Cluster, job.CronJob, aws.ToString(t.Id))
+	}
+	res, err := cweSvc.PutTargets(ctx, &cloudwatchevents.PutTargetsInput{
+		Rule:    &job.CronJob,
+		Targets: p.putTargets,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update targets: %w", err)
+	}
+	for _, e := range res.FailedEntries {
+		logrus.Errorf("failed to update target %s: %s", aws.ToString(e.TargetId), aws.ToString(e.ErrorMessage))
+	}
+	if res.FailedEntryCount != 0 {
+		return fmt.Errorf("failed to update %d targets", res.FailedEntryCount)
+	}
+	return nil
+}
+
+// ruleState returns the rule state of the cron job state, or defaultState if the state is not managed.
+func ruleState(state string, defaultState cwetypes.RuleState) cwetypes.RuleState {
+	switch state {
+	case config.CronJobStateEnabled:
+		return cwetypes.RuleStateEnabled
+	case config.CronJobStateDisabled:
+		return cwetypes.RuleStateDisabled
+	}
+	return defaultState
+}
 
-		listTargetsIn := cloudwatchevents.ListTargetsByRuleInput{
-			Rule: rule.Name,
+func setRuleState(ctx context.Context, cweSvc *cloudwatchevents.Client, name string, state cwetypes.RuleState) error {
+	if state == cwetypes.RuleStateDisabled {
+		_, err := cweSvc.DisableRule(ctx, &cloudwatchevents.DisableRuleInput{Name: &name})
+		return err
+	}
+	_, err := cweSvc.EnableRule(ctx, &cloudwatchevents.EnableRuleInput{Name: &name})
+	return err
+}
+
+// buildCronJobTarget returns the current target with the fields which are described by the cron job config.
+// Fields which config can not describe, such as the capacity provider strategy, the retry policy and the dead-letter config, are kept,
+// and fields which config describes are cleared when they are removed from it. The role is kept unless config sets it.
+func buildCronJobTarget(taskConf config.CronJobTaskConfig, current cwetypes.Target, clusterArn string, tdArn string) (cwetypes.Target, error) {
+	target := current
+	target.Arn = aws.String(clusterArn)
+	if taskConf.RoleArn != "" {
+		target.RoleArn = aws.String(taskConf.RoleArn)
+	}
+	if target.RoleArn == nil {
+		return cwetypes.Target{}, fmt.Errorf("roleArn is required to create the target of cron job %s", taskConf.CronJob)
+	}
+
+	var ecsParams cwetypes.EcsParameters
+	if current.EcsParameters != nil {
+		ecsParams = *current.EcsParameters
+	}
+	ecsParams.TaskDefinitionArn = aws.String(tdArn)
+	ecsParams.LaunchType = cwetypes.LaunchType(taskConf.LaunchType)
+	if ecsParams.LaunchType != "" {
+		// The launch type and the capacity provider strategy are exclusive
+		ecsParams.CapacityProviderStrategy = nil
+	}
+	ecsParams.TaskCount = aws.Int32(max(taskConf.TaskCount, 1))
+	ecsParams.PlatformVersion = nil
+	if taskConf.PlatformVersion != "" {
+		ecsParams.PlatformVersion = aws.String(taskConf.PlatformVersion)
+	}
+	ecsParams.Group = nil
+	if taskConf.Group != "" {
+		ecsParams.Group = aws.String(taskConf.Group)
+	}
+	ecsParams.NetworkConfiguration = nil
+	if nc := taskConf.NetworkConfiguration; nc != nil {
+		ecsParams.NetworkConfiguration = &cwetypes.NetworkConfiguration{
+			AwsvpcConfiguration: &cwetypes.AwsVpcConfiguration{
+				Subnets:        nc.Subnets,
+				SecurityGroups: nc.SecurityGroups,
+				AssignPublicIp: cwetypes.AssignPublicIp(nc.AssignPublicIp),
+			},
 		}
-		targets, err := cweSvc.ListTargetsByRule(ctx, &listTargetsIn)
-		if err != nil {
-			logrus.Errorf("failed to get targets: %s", err)
-			failedCronJobList = append(failedCronJobList, "[cluster: "+taskConf.Cluster+", cron job: "+taskConf.CronJob+"]")
-			continue
+	}
+	ecsParams.Tags = nil
+	if len(taskConf.Tags) != 0 {
+		keys := make([]string, 0, len(taskConf.Tags))
+		for k := range taskConf.Tags {
+			keys = append(keys, k)
 		}
-		for i, target := range targets.Targets {
-			if target.EcsParameters == nil {
-				continue
-			}
-			if *target.EcsParameters.TaskDefinitionArn == tdArn {
-				continue
-			}
-			fmt.Printf("Update cron job [cluster: %s, cronJob: %s, cron: %s]\n", taskConf.Cluster, taskConf.CronJob, taskConf.Cron)
-			targets.Targets[i].EcsParameters.TaskDefinitionArn = &tdArn
+		sort.Strings(keys)
+		var tags []cwetypes.Tag
+		for _, k := range keys {
+			tags = append(tags, cwetypes.Tag{
+				Key:   aws.String(k),
+				Value: aws.String(taskConf.Tags[k]),
+			})
+		}
+		ecsParams.Tags = tags
+	}
+	target.EcsParameters = &ecsParams
+
+	input, err := buildCronJobInput(taskConf, current.Input)
+	if err != nil {
+		return cwetypes.Target{}, err
+	}
+	target.Input = input
+	return target, nil
+}
+
+// buildCronJobInput returns the input of the target with the overrides of the cron job config, or nil if there is nothing to override.
+// Keys of the current input which are not managed by config, such as taskRoleArn, are kept.
+func buildCronJobInput(taskConf config.CronJobTaskConfig, current *string) (*string, error) {
+	currentInput := parseCronJobInput(current)
+	input := newCronJobInput(taskConf)
+	unknown := input.keepUnknown(currentInput)
+	// Keep the current input as is if it is semantically equal
+	if cmp.Equal(currentInput, input, cmpopts.EquateEmpty()) {
+		return current, nil
+	}
+	if len(unknown) != 0 {
+		logrus.Warnf("keys of the target input of cron job %s are not managed by config, they are kept: %s", taskConf.CronJob, strings.Join(unknown, ", "))
+	}
+	if cmp.Equal(input, cronJobInput{}, cmpopts.EquateEmpty()) {
+		return nil, nil
+	}
+	b, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal target input: %w", err)
+	}
+	return aws.String(string(b)), nil
+}
+
+// cronJobInput is the input of the ECS target, which is passed to RunTask as task overrides.
+type cronJobInput struct {
+	ContainerOverrides []cronJobContainerOverride `json:"containerOverrides,omitempty"`
+	Cpu                string                     `json:"cpu,omitempty"`
+	Memory             string                     `json:"memory,omitempty"`
+	// Unknown holds the keys which are not managed by config, such as taskRoleArn
+	Unknown map[string]json.RawMessage `json:"-"`
+	// Raw holds the input which can not be parsed as task overrides
+	Raw string `json:"-"`
+}
+
+func (in cronJobInput) MarshalJSON() ([]byte, error) {
+	type known cronJobInput
+	return marshalWithUnknown(known(in), in.Unknown)
+}
+
+func (in *cronJobInput) UnmarshalJSON(b []byte) error {
+	type known cronJobInput
+	var k known
+	unknown, err := unmarshalWithUnknown(b, &k, "containerOverrides", "cpu", "memory")
+	if err != nil {
+		return err
+	}
+	*in = cronJobInput(k)
+	in.Unknown = unknown
+	return nil
+}
+
+// keepUnknown takes over the keys which are not managed by config from the current input, and returns their paths.
+func (in *cronJobInput) keepUnknown(current cronJobInput) []string {
+	var paths []string
+	in.Unknown = current.Unknown
+	for k := range current.Unknown {
+		paths = append(paths, k)
+	}
+	for _, co := range current.ContainerOverrides {
+		if len(co.Unknown) == 0 {
+			continue
 		}
-		putTargetsIn := &cloudwatchevents.PutTargetsInput{
-			Rule:    &taskConf.CronJob,
-			Targets: targets.Targets,
+		for k := range co.Unknown {
+			paths = append(paths, fmt.Sprintf("containerOverrides[%s].%s", co.Name, k))
 		}
-		if _, err := cweSvc.PutTargets(ctx, putTargetsIn); err != nil {
-			logrus.Errorf("failed to update target: %s", err)
-			failedCronJobList = append(failedCronJobList, "[cluster: "+taskConf.Cluster+", cron job: "+taskConf.CronJob+"]")
+		i := slices.IndexFunc(in.ContainerOverrides, func(o cronJobContainerOverride) bool {
+			return o.Name == co.Name
+		})
+		if i < 0 {
+			in.ContainerOverrides = append(in.ContainerOverrides, cronJobContainerOverride{Name: co.Name, Unknown: co.Unknown})
+			continue
 		}
+		in.ContainerOverrides[i].Unknown = co.Unknown
 	}
-	if len(failedCronJobList) != 0 {
-		return fmt.Errorf("failed to update cron jobs: %s", strings.Join(failedCronJobList, ", "))
+	sort.Strings(paths)
+	return paths
+}
+
+type cronJobContainerOverride struct {
+	Name        string                       `json:"name"`
+	Command     []string                     `json:"command,omitempty"`
+	Environment []cronJobEnvironmentVariable `json:"environment,omitempty"`
+	Cpu         int32                        `json:"cpu,omitempty"`
+	Memory      int32                        `json:"memory,omitempty"`
+	// Unknown holds the keys which are not managed by config, such as environmentFiles
+	Unknown map[string]json.RawMessage `json:"-"`
+}
+
+func (co cronJobContainerOverride) MarshalJSON() ([]byte, error) {
+	type known cronJobContainerOverride
+	return marshalWithUnknown(known(co), co.Unknown)
+}
+
+func (co *cronJobContainerOverride) UnmarshalJSON(b []byte) error {
+	type known cronJobContainerOverride
+	var k known
+	unknown, err := unmarshalWithUnknown(b, &k, "name", "command", "environment", "cpu", "memory")
+	if err != nil {
+		return err
 	}
+	*co = cronJobContainerOverride(k)
+	co.Unknown = unknown
 	return nil
 }
 
+type cronJobEnvironmentVariable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// marshalWithUnknown marshals v with the unknown keys.
+func marshalWithUnknown(v any, unknown map[string]json.RawMessage) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(unknown) == 0 {
+		return b, err
+	}
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for k, raw := range unknown {
+		m[k] = raw
+	}
+	return json.Marshal(m)
+}
+
+// unmarshalWithUnknown unmarshals b into v, and returns the keys of b which are not the known keys.
+func unmarshalWithUnknown(b []byte, v any, known ...string) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, err
+	}
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	var unknown map[string]json.RawMessage
+	for k, raw := range m {
+		// Keys are matched case-insensitively as encoding/json does
+		if slices.ContainsFunc(known, func(s string) bool { return strings.EqualFold(s, k) }) {
+			continue
+		}
+		compact := &bytes.Buffer{}
+		if err := json.Compact(compact, raw); err != nil {
+			return nil, err
+		}
+		if unknown == nil {
+			unknown = map[string]json.RawMessage{}
+		}
+		unknown[k] = compact.Bytes()
+	}
+	return unknown, nil
+}
+
+func newCronJobInput(taskConf config.CronJobTaskConfig) cronJobInput {
+	input := cronJobInput{
+		Cpu:    taskConf.Cpu,
+		Memory: taskConf.Memory,
+	}
+	for _, co := range taskConf.ContainerOverrides {
+		override := cronJobContainerOverride{
+			Name:    co.Name,
+			Command: co.Command,
+			Cpu:     co.Cpu,
+			Memory:  co.Memory,
+		}
+		for _, env := range co.Environment {
+			override.Environment = append(override.Environment, cronJobEnvironmentVariable{
+				Name:  env.Name,
+				Value: env.Value,
+			})
+		}
+		input.ContainerOverrides = append(input.ContainerOverrides, override)
+	}
+	return input
+}
+
+func parseCronJobInput(s *string) cronJobInput {
+	input := cronJobInput{}
+	if s == nil || *s == "" {
+		return input
+	}
+	if err := json.Unmarshal([]byte(*s), &input); err != nil {
+		return cronJobInput{Raw: *s}
+	}
+	return input
+}
+
+// cronJobComparer compares rule targets and schedules, so that deploy and drift agree on whether they differ from config.
+// Sensitive values of the input are masked by the redactor, or compared as they are if it is nil.
+func cronJobComparer(redactor *redact.Redactor) cmp.Option {
+	return cmp.Options{
+		cmpopts.IgnoreTypes(document.NoSerde{}),
+		cronJobInputComparer(redactor),
+		optionalBoolComparer,
+	}
+}
+
+// optionalBoolComparer compares unset bool fields as false, since they are returned either way.
+var optionalBoolComparer = cmp.Comparer(func(a, b *bool) bool {
+	return aws.ToBool(a) == aws.ToBool(b)
+})
+
+// cronJobInputComparer compares the input JSON of targets as task overrides,
+// whose sensitive environment values are masked by the redactor so that the diff can be shown.
+func cronJobInputComparer(redactor *redact.Redactor) cmp.Option {
+	return cmp.FilterPath(func(p cmp.Path) bool {
+		sf, ok := p.Last().(cmp.StructField)
+		return ok && sf.Name() == "Input"
+	}, cmp.Transformer("ParseInput", func(s *string) cronJobInput {
+		return redactCronJobInput(parseCronJobInput(s), redactor)
+	}))
+}
+
+// redactCronJobInput returns the copy of the input whose sensitive environment values are masked.
+func redactCronJobInput(in cronJobInput, redactor *redact.Redactor) cronJobInput {
+	if redactor == nil {
+		return in
+	}
+	if in.Raw != "" && redactor.Sensitive("", in.Raw) {
+		in.Raw = redact.Mask(in.Raw)
+	}
+	overrides := make([]cronJobContainerOverride, len(in.ContainerOverrides))
+	for i, co := range in.ContainerOverrides {
+		env := make([]cronJobEnvironmentVariable, len(co.Environment))
+		for j, e := range co.Environment {
+			if redactor.Sensitive(e.Name, e.Value) {
+				e.Value = redact.Mask(e.Value)
+			}
+			env[j] = e
+		}
+		if co.Environment != nil {
+			co.Environment = env
+		}
+		overrides[i] = co
+	}
+	if in.ContainerOverrides != nil {
+		in.ContainerOverrides = overrides
+	}
+	return in
+}
+
+func describeClusterArn(ctx context.Context, svc *ecs.Client, cluster string) (string, error) {
+	res, err := svc.DescribeClusters(ctx, &ecs.DescribeClustersInput{
+		Clusters: []string{cluster},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(res.Clusters) == 0 {
+		return "", fmt.Errorf("cluster %s is not found", cluster)
+	}
+	return *res.Clusters[0].ClusterArn, nil
+}
+
 func replaceLowerCaseKey(data map[string]interface{}) map[string]interface{} {
 	result := map[string]interface{}{}
 	for k, v := range data {
@@ -346,9 +929,11 @@ func visitElements(data []interface{}) []interface{} {
 func displayColorDiff(diff string) {
 	for _, s := range strings.Split(diff, "\n") {
 		if strings.HasPrefix(s, "+") {
-			fmt.Println(aurora.Green(s))
+			fmt.Println(au.Green(s))
 		} else if strings.HasPrefix(s, "-") {
-			fmt.Println(aurora.Red(s))
+			fmt.Println(au.Red(s))
+		} else if strings.HasPrefix(s, "@@") {
+			fmt.Println(au.Cyan(s))
 		} else {
 			fmt.Println(s)
 		}
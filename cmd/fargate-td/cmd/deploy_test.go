@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cwetypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchevents/types"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	schtypes "github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+	"github.com/aws/smithy-go/document"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/kazz187/fargate-td/internal/config"
+)
+
+const (
+	testClusterArn = "arn:aws:ecs:ap-northeast-1:123456789012:cluster/production"
+	testTdArn      = "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/batch:2"
+	testRoleArn    = "arn:aws:iam::123456789012:role/ecsEventsRole"
+)
+
+func TestBuildCronJobTarget(t *testing.T) {
+	spot := []cwetypes.CapacityProviderStrategyItem{{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 1}}
+	current := cwetypes.Target{
+		Id:      aws.String("daily-report"),
+		Arn:     aws.String(testClusterArn),
+		RoleArn: aws.String(testRoleArn),
+		EcsParameters: &cwetypes.EcsParameters{
+			TaskDefinitionArn:        aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/batch:1"),
+			CapacityProviderStrategy: spot,
+			PropagateTags:            cwetypes.PropagateTagsTaskDefinition,
+			EnableExecuteCommand:     true,
+			Group:                    aws.String("old"),
+			TaskCount:                aws.Int32(1),
+		},
+		RetryPolicy:      &cwetypes.RetryPolicy{MaximumRetryAttempts: aws.Int32(3)},
+		DeadLetterConfig: &cwetypes.DeadLetterConfig{Arn: aws.String("arn:aws:sqs:ap-northeast-1:123456789012:dlq")},
+	}
+	tests := []struct {
+		name    string
+		conf    config.CronJobTaskConfig
+		current cwetypes.Target
+		want    cwetypes.Target
+		wantErr bool
+	}{
+		{
+			name:    "fields which config can not describe are kept",
+			conf:    config.CronJobTaskConfig{CronJob: "daily-report", PlatformVersion: "LATEST"},
+			current: current,
+			want: cwetypes.Target{
+				Id:      aws.String("daily-report"),
+				Arn:     aws.String(testClusterArn),
+				RoleArn: aws.String(testRoleArn),
+				EcsParameters: &cwetypes.EcsParameters{
+					TaskDefinitionArn:        aws.String(testTdArn),
+					CapacityProviderStrategy: spot,
+					PropagateTags:            cwetypes.PropagateTagsTaskDefinition,
+					EnableExecuteCommand:     true,
+					PlatformVersion:          aws.String("LATEST"),
+					TaskCount:                aws.Int32(1),
+				},
+				RetryPolicy:      current.RetryPolicy,
+				DeadLetterConfig: current.DeadLetterConfig,
+			},
+		},
+		{
+			name:    "launch type replaces the capacity provider strategy",
+			conf:    config.CronJobTaskConfig{CronJob: "daily-report", RoleArn: "arn:aws:iam::123456789012:role/newRole", LaunchType: "FARGATE", TaskCount: 2},
+			current: current,
+			want: cwetypes.Target{
+				Id:      aws.String("daily-report"),
+				Arn:     aws.String(testClusterArn),
+				RoleArn: aws.String("arn:aws:iam::123456789012:role/newRole"),
+				EcsParameters: &cwetypes.EcsParameters{
+					TaskDefinitionArn:    aws.String(testTdArn),
+					LaunchType:           cwetypes.LaunchTypeFargate,
+					PropagateTags:        cwetypes.PropagateTagsTaskDefinition,
+					EnableExecuteCommand: true,
+					TaskCount:            aws.Int32(2),
+				},
+				RetryPolicy:      current.RetryPolicy,
+				DeadLetterConfig: current.DeadLetterConfig,
+			},
+		},
+		{
+			name:    "role is required to create the target",
+			conf:    config.CronJobTaskConfig{CronJob: "daily-report"},
+			current: cwetypes.Target{Id: aws.String("daily-report")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildCronJobTarget(tt.conf, tt.current, testClusterArn, testTdArn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildCronJobTarget() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got, cmpopts.IgnoreTypes(document.NoSerde{})); diff != "" {
+				t.Errorf("buildCronJobTarget() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBuildScheduleInputKeepsTarget(t *testing.T) {
+	current := &scheduler.GetScheduleOutput{
+		Target: &schtypes.Target{
+			Arn:     aws.String(testClusterArn),
+			RoleArn: aws.String(testRoleArn),
+			EcsParameters: &schtypes.EcsParameters{
+				TaskDefinitionArn:        aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/batch:1"),
+				CapacityProviderStrategy: []schtypes.CapacityProviderStrategyItem{{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 1}},
+				PlacementStrategy:        []schtypes.PlacementStrategy{{Type: schtypes.PlacementStrategyTypeSpread, Field: aws.String("attribute:ecs.availability-zone")}},
+				TaskCount:                aws.Int32(1),
+			},
+			RetryPolicy: &schtypes.RetryPolicy{MaximumRetryAttempts: aws.Int32(3)},
+		},
+	}
+	in, err := buildScheduleInput(config.CronJobTaskConfig{CronJob: "daily-report", Cron: "cron(0 3 * * ? *)"}, current, testClusterArn, testTdArn)
+	if err != nil {
+		t.Fatalf("buildScheduleInput() error: %v", err)
+	}
+	want := &schtypes.Target{
+		Arn:     aws.String(testClusterArn),
+		RoleArn: aws.String(testRoleArn),
+		EcsParameters: &schtypes.EcsParameters{
+			TaskDefinitionArn:        aws.String(testTdArn),
+			CapacityProviderStrategy: current.Target.EcsParameters.CapacityProviderStrategy,
+			PlacementStrategy:        current.Target.EcsParameters.PlacementStrategy,
+			EnableECSManagedTags:     aws.Bool(false),
+			EnableExecuteCommand:     aws.Bool(false),
+			TaskCount:                aws.Int32(1),
+		},
+	}
+	// The retry policy of schedules is described by config, so it is cleared
+	if diff := cmp.Diff(want, in.Target, cmpopts.IgnoreTypes(document.NoSerde{})); diff != "" {
+		t.Errorf("buildScheduleInput() target mismatch (-want +got):\n%s", diff)
+	}
+}
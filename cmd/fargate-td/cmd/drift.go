@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchevents"
+	cwetypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchevents/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/kazz187/fargate-td/internal/config"
+	"github.com/kazz187/fargate-td/internal/report"
+	"github.com/kazz187/fargate-td/internal/taskdef"
+)
+
+const (
+	// driftExitCode is the exit code when drift is detected, which differs from 1 of other errors.
+	driftExitCode = 3
+	// driftFailedExitCode is the exit code when some targets could not be checked.
+	driftFailedExitCode = 2
+)
+
+func DriftCommand(ftr *FargateTdRunner) *cobra.Command {
+	r := &DriftRunner{
+		GenerateRunner: GenerateRunner{
+			VariablesRunner: *NewVariablesRunner(),
+		},
+	}
+	c := &cobra.Command{
+		Use:   `drift (-p PATH | --all) [-t TASK] -v"Key=Value"`,
+		Short: "Detect drift of services and cron jobs",
+		Long: `Detect drift of services and cron jobs
+
+Render the task definitions without registering them, and compare them with the
+task definitions used by the services and cron jobs in config.yml.
+The schedules of cron jobs are compared with config.yml as well.
+Exit with 3 if drift is detected, or 2 if some targets could not be checked.
+
+Run 'fargate-td drift (-p PATH | --all) [-t TASK] -v"Key=Value"
+
+    $ fargate-td drift --all -v"Version=0.0.1"`,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+		// Drift is reported by the exit code, the usage is not helpful
+		SilenceUsage: true,
+	}
+	c.Flags().StringVarP(&r.TargetTaskPath, "path", "p", "", "target path")
+	c.Flags().StringVarP(&r.ProjectRootPath, "root_path", "r", "", "project root path")
+	c.Flags().StringToStringVarP(&r.Variables, "var", "v", map[string]string{}, "variables (key1=value1,key2=value2)")
+	c.Flags().StringVarP(&r.TaskName, "task", "t", "", "task name (default: all tasks in config)")
+	c.Flags().BoolVar(&r.All, "all", false, "check all target paths which have config.yml")
+	c.Flags().BoolVarP(&ftr.Debug, "debug", "d", false, "debug option")
+	SetReportOptions(c, &r.ReportOptions)
+	SetRedactionOptions(c, &r.GenerateRunner)
+	r.Command = c
+	return c
+}
+
+type DriftRunner struct {
+	GenerateRunner
+	ReportOptions
+	All bool
+}
+
+func (r *DriftRunner) preRunE(c *cobra.Command, args []string) error {
+	if r.All && r.TargetTaskPath != "" {
+		return errors.New("--path and --all can not be used together")
+	}
+	if !r.All && r.TargetTaskPath == "" {
+		return errors.New("--path is required without --all")
+	}
+	if err := r.ReportOptions.validate(); err != nil {
+		return err
+	}
+	return r.GenerateRunner.preRunE(c, args)
+}
+
+func (r *DriftRunner) runE(c *cobra.Command, args []string) error {
+	ctx := context.Background()
+	paths, err := r.targetPaths()
+	if err != nil {
+		return err
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+	d := &driftDetector{
+		ecsSvc:      ecs.NewFromConfig(cfg),
+		cweSvc:      cloudwatchevents.NewFromConfig(cfg),
+		schSvc:      scheduler.NewFromConfig(cfg),
+		clusterArns: map[string]string{},
+		rep:         report.New("Drift"),
+	}
+
+	taskFound := false
+	for _, path := range paths {
+		deployConf, err := loadDeployConfig(r.ProjectRootPath, path)
+		if err != nil {
+			logrus.Errorf("failed to load deploy config of %s: %s", path, err)
+			d.failed++
+			continue
+		}
+		tasks := deployConf.Tasks()
+		if r.TaskName != "" {
+			// Paths whose config does not use the task are not targets
+			if !slices.Contains(tasks, r.TaskName) {
+				continue
+			}
+			tasks = []string{r.TaskName}
+		}
+		taskFound = taskFound || len(tasks) != 0
+		for _, task := range tasks {
+			gr := r.GenerateRunner
+			gr.TargetTaskPath = path
+			gr.TaskName = task
+			rendered, err := gr.GenerateRegisterTaskDefinitionInput()
+			if err != nil {
+				logrus.Errorf("failed to render task %s of %s: %s", task, path, err)
+				d.failed++
+				continue
+			}
+			redactor, err := gr.Redactor()
+			if err != nil {
+				logrus.Errorf("failed to load redaction of %s: %s", path, err)
+				d.failed++
+				continue
+			}
+			checker := newRevisionChecker(d.ecsSvc, rendered, redactor)
+			d.detectServices(ctx, checker, path, task, deployConf.GetServicesMapGroupByCluster(task))
+			for _, job := range deployConf.GetCronJobTaskConfigs(task) {
+				d.detectCronJob(ctx, checker, path, task, job)
+			}
+		}
+	}
+
+	if r.TaskName != "" && !taskFound && d.failed == 0 {
+		return fmt.Errorf("task %s is not used in config of the target paths", r.TaskName)
+	}
+	fmt.Printf("Checked %d targets: %d drifted, %d failed\n", d.checked, d.drifted, d.failed)
+	if err := r.ReportOptions.write(d.rep); err != nil {
+		return err
+	}
+	if d.failed != 0 {
+		return &ExitError{
+			Code:    driftFailedExitCode,
+			Message: fmt.Sprintf("failed to check drift of %d targets", d.failed),
+		}
+	}
+	if d.drifted != 0 {
+		return &ExitError{
+			Code:    driftExitCode,
+			Message: fmt.Sprintf("drift is detected in %d targets", d.drifted),
+		}
+	}
+	return nil
+}
+
+// targetPaths returns the target paths to check.
+// With --all, every directory under the tasks directory which has a deploy config is a target path.
+func (r *DriftRunner) targetPaths() ([]string, error) {
+	if !r.All {
+		return []string{r.TargetTaskPath}, nil
+	}
+	taskRootPath := filepath.Join(r.ProjectRootPath, taskPath)
+	found := map[string]bool{}
+	err := filepath.WalkDir(taskRootPath, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || (entry.Name() != "config.yml" && entry.Name() != "config.yaml") {
+			return nil
+		}
+		rel, err := filepath.Rel(taskRootPath, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		found[filepath.Clean("/"+rel)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search deploy config files: %w", err)
+	}
+	paths := make([]string, 0, len(found))
+	for p := range found {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("deploy config file is not found in %s", taskRootPath)
+	}
+	return paths, nil
+}
+
+// driftDetector compares the live services and cron jobs with the repository, and counts the results.
+type driftDetector struct {
+	ecsSvc      *ecs.Client
+	cweSvc      *cloudwatchevents.Client
+	schSvc      *scheduler.Client
+	clusterArns map[string]string
+	rep         *report.Report
+
+	checked int
+	drifted int
+	failed  int
+}
+
+// target adds the target to the report.
+func (d *driftDetector) target(kind, path, task, cluster, name string) *report.Target {
+	t := d.rep.Add(kind, cluster, name)
+	t.Path = path
+	t.Task = task
+	return t
+}
+
+// addTaskDefinitionDrift adds the change of the task definition to the target.
+func addTaskDefinitionDrift(t *report.Target, message string, tdArn string, change *taskdef.Change) {
+	t.CurrentTaskDefinitionArn = tdArn
+	t.AddNote(report.StatusChanged, message, "")
+	t.AddChange(change)
+}
+
+// report prints the drift of the target and counts it.
+func (d *driftDetector) report(t *report.Target, err error) {
+	d.checked++
+	if err != nil {
+		logrus.Errorf("failed to check drift of %s: %s", t.Label(), err)
+		t.SetError(err)
+		d.failed++
+		return
+	}
+	if t.Status == report.StatusUpToDate {
+		fmt.Printf("Up-to-date %s\n", t.Label())
+		return
+	}
+	d.drifted++
+	fmt.Printf("%s %s\n", au.Yellow("Drift"), t.Label())
+	for _, n := range t.Notes {
+		fmt.Printf("- %s\n", n.Message)
+		if n.Diff != "" {
+			fmt.Println("```")
+			displayColorDiff(n.Diff)
+			fmt.Println("```")
+		}
+	}
+	if t.Diff != "" {
+		displayTaskDefinitionChange(&taskdef.Change{
+			Summary: t.Summary,
+			Diff:    t.Diff,
+		})
+	}
+}
+
+func (d *driftDetector) detectServices(ctx context.Context, checker *revisionChecker, path string, task string, servicesMap map[string][]string) {
+	clusters := make([]string, 0, len(servicesMap))
+	for cluster := range servicesMap {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+	for _, cluster := range clusters {
+		services := servicesMap[cluster]
+		for i := 0; i < len(services); i += maxDescribeServices {
+			end := min(i+maxDescribeServices, len(services))
+			svcRes, err := d.ecsSvc.DescribeServices(ctx, &ecs.DescribeServicesInput{
+				Cluster:  &cluster,
+				Services: services[i:end],
+			})
+			if err != nil {
+				err = fmt.Errorf("failed to describe services: %w", err)
+			}
+			found := map[string]types.Service{}
+			if svcRes != nil {
+				for _, s := range svcRes.Services {
+					found[aws.ToString(s.ServiceName)] = s
+				}
+			}
+			for _, name := range services[i:end] {
+				t := d.target(report.KindService, path, task, cluster, name)
+				if err != nil {
+					d.report(t, err)
+					continue
+				}
+				s, ok := found[name]
+				if !ok {
+					t.AddNote(report.StatusChanged, "service is not found", "")
+					d.report(t, nil)
+					continue
+				}
+				tdArn := aws.ToString(s.TaskDefinition)
+				change, changeErr := checker.change(ctx, tdArn)
+				if change != nil {
+					addTaskDefinitionDrift(t, fmt.Sprintf("task definition %s differs from repository", tdArn), tdArn, change)
+				}
+				d.report(t, changeErr)
+			}
+		}
+	}
+}
+
+func (d *driftDetector) detectCronJob(ctx context.Context, checker *revisionChecker, path string, task string, job config.CronJobTaskConfig) {
+	t := d.target(report.KindCronJob, path, task, job.Cluster, job.CronJob)
+	var err error
+	if job.UsesScheduler() {
+		err = d.detectSchedule(ctx, checker, job, t)
+	} else {
+		err = d.detectRule(ctx, checker, job, t)
+	}
+	d.report(t, err)
+}
+
+func (d *driftDetector) detectRule(ctx context.Context, checker *revisionChecker, job config.CronJobTaskConfig, t *report.Target) error {
+	rule, err := d.cweSvc.DescribeRule(ctx, &cloudwatchevents.DescribeRuleInput{
+		Name: &job.CronJob,
+	})
+	if err != nil {
+		var notFound *cwetypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			t.AddNote(report.StatusChanged, "rule is not found", "")
+			return nil
+		}
+		return fmt.Errorf("failed to get rule: %w", err)
+	}
+	if expr := aws.ToString(rule.ScheduleExpression); expr != job.Cron {
+		t.AddNote(report.StatusChanged, fmt.Sprintf("schedule %s differs from %s in config", expr, job.Cron), "")
+	}
+	if state := ruleState(job.State, rule.State); state != rule.State {
+		t.AddNote(report.StatusChanged, fmt.Sprintf("state %s differs from %s in config", rule.State, state), "")
+	}
+	targets, err := d.cweSvc.ListTargetsByRule(ctx, &cloudwatchevents.ListTargetsByRuleInput{
+		Rule: rule.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get targets: %w", err)
+	}
+	clusterArn, err := d.clusterArn(ctx, job.Cluster)
+	if err != nil {
+		return err
+	}
+	hasEcsTarget := false
+	for _, et := range targets.Targets {
+		if et.EcsParameters == nil {
+			continue
+		}
+		hasEcsTarget = true
+		tdArn := aws.ToString(et.EcsParameters.TaskDefinitionArn)
+		change, err := checker.change(ctx, tdArn)
+		if err != nil {
+			return err
+		}
+		if change != nil {
+			addTaskDefinitionDrift(t, fmt.Sprintf("task definition %s of target %s differs from repository", tdArn, aws.ToString(et.Id)), tdArn, change)
+		}
+		newTarget, err := buildCronJobTarget(job, et, clusterArn, tdArn)
+		if err != nil {
+			return err
+		}
+		if diff := cmp.Diff(et, newTarget, cronJobComparer(checker.redactor)); diff != "" {
+			t.AddNote(report.StatusChanged, fmt.Sprintf("target %s differs from config", aws.ToString(et.Id)), diff)
+		}
+	}
+	if !hasEcsTarget {
+		t.AddNote(report.StatusChanged, "ECS target is not found", "")
+	}
+	return nil
+}
+
+func (d *driftDetector) detectSchedule(ctx context.Context, checker *revisionChecker, job config.CronJobTaskConfig, t *report.Target) error {
+	schedule, err := getSchedule(ctx, d.schSvc, job)
+	if err != nil {
+		return fmt.Errorf("failed to get schedule: %w", err)
+	}
+	if schedule == nil {
+		t.AddNote(report.StatusChanged, "schedule is not found", "")
+		return nil
+	}
+	if schedule.Target == nil || schedule.Target.EcsParameters == nil {
+		t.AddNote(report.StatusChanged, "ECS target is not found", "")
+		return nil
+	}
+	tdArn := aws.ToString(schedule.Target.EcsParameters.TaskDefinitionArn)
+	change, err := checker.change(ctx, tdArn)
+	if err != nil {
+		return err
+	}
+	if change != nil {
+		addTaskDefinitionDrift(t, fmt.Sprintf("task definition %s differs from repository", tdArn), tdArn, change)
+	}
+	clusterArn, err := d.clusterArn(ctx, job.Cluster)
+	if err != nil {
+		return err
+	}
+	in, err := buildScheduleInput(job, schedule, clusterArn, tdArn)
+	if err != nil {
+		return err
+	}
+	if diff := cmp.Diff(scheduleInputFromOutput(schedule), in, cronJobComparer(checker.redactor)); diff != "" {
+		t.AddNote(report.StatusChanged, "schedule differs from config", diff)
+	}
+	return nil
+}
+
+func (d *driftDetector) clusterArn(ctx context.Context, cluster string) (string, error) {
+	if arn, ok := d.clusterArns[cluster]; ok {
+		return arn, nil
+	}
+	arn, err := describeClusterArn(ctx, d.ecsSvc, cluster)
+	if err != nil {
+		return "", fmt.Errorf("failed to get cluster: %w", err)
+	}
+	d.clusterArns[cluster] = arn
+	return arn, nil
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+
+	"github.com/kazz187/fargate-td/internal/config"
+	"github.com/kazz187/fargate-td/internal/report"
+	"github.com/kazz187/fargate-td/internal/taskdef"
+)
+
+// httpClientFunc responds to the requests of AWS clients without sending them.
+type httpClientFunc func(*http.Request) (*http.Response, error)
+
+func (f httpClientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func jsonResponse(body string) httpClientFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	}
+}
+
+func TestDetectSchedule(t *testing.T) {
+	job := config.CronJobTaskConfig{
+		Cluster:       "production",
+		CronJob:       "daily-report",
+		Cron:          "cron(0 3 * * ? *)",
+		RoleArn:       testRoleArn,
+		ScheduleGroup: "default",
+		Scheduler:     config.SchedulerEventBridgeScheduler,
+	}
+	// EnableECSManagedTags and EnableExecuteCommand are not returned
+	schSvc := scheduler.New(scheduler.Options{
+		Region:      "ap-northeast-1",
+		Credentials: aws.AnonymousCredentials{},
+		HTTPClient: jsonResponse(`{
+			"Name": "daily-report",
+			"GroupName": "default",
+			"ScheduleExpression": "cron(0 3 * * ? *)",
+			"State": "ENABLED",
+			"FlexibleTimeWindow": {"Mode": "OFF"},
+			"Target": {
+				"Arn": "` + testClusterArn + `",
+				"RoleArn": "` + testRoleArn + `",
+				"EcsParameters": {"TaskDefinitionArn": "` + testTdArn + `", "TaskCount": 1}
+			}
+		}`),
+	})
+	d := &driftDetector{
+		schSvc:      schSvc,
+		clusterArns: map[string]string{job.Cluster: testClusterArn},
+		rep:         &report.Report{},
+	}
+	checker := &revisionChecker{changes: map[string]*taskdef.Change{testTdArn: nil}}
+	target := d.rep.Add(report.KindCronJob, job.Cluster, job.CronJob)
+	if err := d.detectSchedule(context.Background(), checker, job, target); err != nil {
+		t.Fatalf("detectSchedule() error: %v", err)
+	}
+	if len(target.Notes) != 0 {
+		t.Errorf("detectSchedule() notes = %+v, want no drift", target.Notes)
+	}
+}
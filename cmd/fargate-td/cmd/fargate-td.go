@@ -3,10 +3,29 @@ package cmd
 import (
 	"os"
 
+	"github.com/logrusorgru/aurora/v3"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
+// au colors the output only when stdout is a terminal.
+var au = aurora.NewAurora(isTerminal(os.Stdout))
+
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// ExitError is returned by commands which exit with a specific exit code.
+type ExitError struct {
+	Code    int
+	Message string
+}
+
+func (e *ExitError) Error() string {
+	return e.Message
+}
+
 type FargateTdRunner struct {
 	Debug bool
 }
@@ -24,6 +43,13 @@ func NewFargateTdCommand() *cobra.Command {
 	root.AddCommand(GenerateCommand(&ftr))
 	root.AddCommand(DeployCommand(&ftr))
 	root.AddCommand(WatchCommand(&ftr))
+	root.AddCommand(CronCommand(&ftr))
+	root.AddCommand(ScheduleCommand(&ftr))
+	root.AddCommand(RunCommand(&ftr))
+	root.AddCommand(LogsCommand(&ftr))
+	root.AddCommand(StatusCommand(&ftr))
+	root.AddCommand(DriftCommand(&ftr))
+	root.AddCommand(LockCommand(&ftr))
 	return root
 }
 
@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	goyaml "gopkg.in/yaml.v3"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 
+	"github.com/kazz187/fargate-td/internal/config"
 	"github.com/kazz187/fargate-td/internal/overlay"
+	"github.com/kazz187/fargate-td/internal/redact"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -31,6 +35,7 @@ Run 'fargate-td generate -p PATH -t TASK -v"Key=Value"
 		RunE:    r.runE,
 	}
 	SetGenerateOptions(c, ftr, r)
+	SetRedactionOptions(c, r)
 	r.Command = c
 	return c
 }
@@ -41,9 +46,14 @@ func SetGenerateOptions(c *cobra.Command, ftr *FargateTdRunner, r *GenerateRunne
 	_ = c.MarkFlagRequired("task")
 }
 
+func SetRedactionOptions(c *cobra.Command, r *GenerateRunner) {
+	c.Flags().BoolVar(&r.ShowSecrets, "show-secrets", false, "show values of sensitive environment variables")
+}
+
 type GenerateRunner struct {
 	VariablesRunner
-	TaskName string
+	TaskName    string
+	ShowSecrets bool
 }
 
 func (r *GenerateRunner) preRunE(c *cobra.Command, args []string) error {
@@ -62,10 +72,50 @@ func (r *GenerateRunner) runE(c *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	redactor, err := r.Redactor()
+	if err != nil {
+		return err
+	}
+	taskStr, err = redactor.YAML(taskStr)
+	if err != nil {
+		return err
+	}
 	fmt.Print(taskStr)
 	return nil
 }
 
+// Redactor returns the redactor of sensitive values in the output, or nil if --show-secrets is specified.
+func (r *GenerateRunner) Redactor() (*redact.Redactor, error) {
+	if r.ShowSecrets {
+		return nil, nil
+	}
+	// The config file is optional for generate, then only the default patterns are used
+	var redaction config.RedactionConfig
+	deployConf, err := loadDeployConfig(r.ProjectRootPath, r.TargetTaskPath)
+	if err == nil {
+		redaction = deployConf.Redaction()
+	} else if !errors.Is(err, config.ErrConfigNotFound) {
+		return nil, err
+	}
+	var values []string
+	if len(redaction.Variables) != 0 {
+		vars, err := r.VariablesRunner.LoadVariables()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range redaction.Variables {
+			v, err := vars.Pipe(yaml.Lookup(strings.Split(name, ".")...))
+			if err != nil {
+				return nil, fmt.Errorf("failed to lookup variable %s: %w", name, err)
+			}
+			if v != nil && v.YNode().Kind == yaml.ScalarNode {
+				values = append(values, v.YNode().Value)
+			}
+		}
+	}
+	return redact.New(redaction.Patterns, values)
+}
+
 func (r *GenerateRunner) GenerateTaskDefinition() (string, error) {
 	vars, err := r.VariablesRunner.LoadVariables()
 	if err != nil {
@@ -131,3 +181,30 @@ func (r *GenerateRunner) GenerateTaskDefinition() (string, error) {
 	logrus.Debugln("generated variables:", varsStr)
 	return taskStr, nil
 }
+
+func (r *GenerateRunner) GenerateRegisterTaskDefinitionInput() (*ecs.RegisterTaskDefinitionInput, error) {
+	taskStr, err := r.GenerateTaskDefinition()
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace keys of task yaml to lowercase
+	taskYaml := map[string]interface{}{}
+	err = goyaml.Unmarshal([]byte(taskStr), &taskYaml)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task yaml")
+	}
+	replacedTaskYaml := replaceLowerCaseKey(taskYaml)
+	inStr, err := goyaml.Marshal(replacedTaskYaml)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task yaml")
+	}
+
+	// Load to struct RegisterTaskDefinitionInput
+	in := &ecs.RegisterTaskDefinitionInput{}
+	err = goyaml.Unmarshal(inStr, in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load task definition yaml file: %w", err)
+	}
+	return in, nil
+}
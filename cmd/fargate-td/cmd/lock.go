@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/spf13/cobra"
+
+	"github.com/kazz187/fargate-td/internal/config"
+	"github.com/kazz187/fargate-td/internal/lock"
+)
+
+func LockCommand(ftr *FargateTdRunner) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "lock",
+		Short: "Manage deploy locks",
+		Long: `Manage deploy locks
+
+Run 'fargate-td lock COMMAND -p PATH -t TASK
+
+    $ fargate-td lock status -p app1/production -t task1`,
+	}
+	c.AddCommand(LockStatusCommand(ftr))
+	c.AddCommand(LockReleaseCommand(ftr))
+	return c
+}
+
+func SetLockOptions(c *cobra.Command, ftr *FargateTdRunner, r *LockRunner) {
+	c.Flags().StringVarP(&r.TaskName, "task", "t", "", "task name")
+	_ = c.MarkFlagRequired("task")
+	c.Flags().StringVarP(&r.TargetTaskPath, "path", "p", "", "target path")
+	_ = c.MarkFlagRequired("path")
+	c.Flags().StringVarP(&r.ProjectRootPath, "root_path", "r", "", "project root path")
+	c.Flags().BoolVarP(&ftr.Debug, "debug", "d", false, "debug option")
+}
+
+type LockRunner struct {
+	Command         *cobra.Command
+	TaskName        string
+	TargetTaskPath  string
+	ProjectRootPath string
+}
+
+func (r *LockRunner) preRunE(c *cobra.Command, args []string) error {
+	if r.ProjectRootPath == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		r.ProjectRootPath = wd
+	} else {
+		var err error
+		r.ProjectRootPath, err = filepath.Abs(r.ProjectRootPath)
+		if err != nil {
+			return err
+		}
+	}
+	// Must contain prefix "/"
+	r.TargetTaskPath = filepath.Clean("/" + r.TargetTaskPath)
+	if strings.Contains(r.TaskName, "/") {
+		return fmt.Errorf(`invalid task name (contains "/")`)
+	}
+	return nil
+}
+
+// locker returns the locker of the task and the key of the lock.
+func (r *LockRunner) locker(ctx context.Context) (lock.Locker, string, error) {
+	deployConf, err := loadDeployConfig(r.ProjectRootPath, r.TargetTaskPath)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load aws config: %w", err)
+	}
+	locker, err := newLocker(ctx, cfg, r.ProjectRootPath, deployConf, r.TaskName)
+	if err != nil {
+		return nil, "", err
+	}
+	if locker == nil {
+		return nil, "", errors.New("lock is not configured in config.yml")
+	}
+	return locker, lockKey(r.TargetTaskPath, r.TaskName), nil
+}
+
+func LockStatusCommand(ftr *FargateTdRunner) *cobra.Command {
+	r := &LockStatusRunner{}
+	c := &cobra.Command{
+		Use:   "status -p PATH -t TASK",
+		Short: "Show the deploy lock of the task",
+		Long: `Show the deploy lock of the task
+
+Run 'fargate-td lock status -p PATH -t TASK
+
+    $ fargate-td lock status -p app1/production -t task1`,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	SetLockOptions(c, ftr, &r.LockRunner)
+	r.Command = c
+	return c
+}
+
+type LockStatusRunner struct {
+	LockRunner
+}
+
+func (r *LockStatusRunner) runE(c *cobra.Command, args []string) error {
+	ctx := context.Background()
+	locker, key, err := r.locker(ctx)
+	if err != nil {
+		return err
+	}
+	l, err := locker.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		fmt.Printf("Not locked [key: %s]\n", key)
+		return nil
+	}
+	state := au.Yellow("Locked")
+	if l.Expired(time.Now()) {
+		state = au.Gray(12, "Expired")
+	}
+	fmt.Printf("%s [key: %s]\n", state, key)
+	fmt.Printf("  Owner: %s\n", l.Owner)
+	fmt.Printf("  Acquired: %s\n", formatStatusTime(&l.AcquiredAt))
+	fmt.Printf("  Expires: %s\n", formatStatusTime(&l.ExpiresAt))
+	return nil
+}
+
+func LockReleaseCommand(ftr *FargateTdRunner) *cobra.Command {
+	r := &LockReleaseRunner{}
+	c := &cobra.Command{
+		Use:   "release -p PATH -t TASK",
+		Short: "Release the deploy lock of the task",
+		Long: `Release the deploy lock of the task
+
+The lock is released regardless of its owner, for example after a deploy is killed.
+
+Run 'fargate-td lock release -p PATH -t TASK
+
+    $ fargate-td lock release -p app1/production -t task1`,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	SetLockOptions(c, ftr, &r.LockRunner)
+	r.Command = c
+	return c
+}
+
+type LockReleaseRunner struct {
+	LockRunner
+}
+
+func (r *LockReleaseRunner) runE(c *cobra.Command, args []string) error {
+	ctx := context.Background()
+	locker, key, err := r.locker(ctx)
+	if err != nil {
+		return err
+	}
+	l, err := locker.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		fmt.Printf("Not locked [key: %s]\n", key)
+		return nil
+	}
+	if err := locker.ForceRelease(ctx, key); err != nil {
+		return err
+	}
+	fmt.Printf("Released lock [key: %s, owner: %s]\n", key, l.Owner)
+	return nil
+}
+
+// newLocker returns the locker of the backend in config, or nil if the lock is not configured.
+func newLocker(ctx context.Context, cfg aws.Config, projectRootPath string, deployConf *config.DeployConfig, task string) (lock.Locker, error) {
+	lockConf := deployConf.Lock()
+	switch lockConf.Backend {
+	case config.LockBackendDynamoDB:
+		return lock.NewDynamoDBLocker(dynamodb.NewFromConfig(cfg), lockConf.Table), nil
+	case config.LockBackendECSTag:
+		cluster, service := lockConf.Cluster, lockConf.Service
+		if service == "" {
+			services := deployConf.GetServiceTaskConfigs(task)
+			if len(services) == 0 {
+				return nil, fmt.Errorf("service to be tagged by lock is not found for task %s", task)
+			}
+			cluster, service = services[0].Cluster, services[0].Service
+		}
+		ecsSvc := ecs.NewFromConfig(cfg)
+		res, err := ecsSvc.DescribeServices(ctx, &ecs.DescribeServicesInput{
+			Cluster:  &cluster,
+			Services: []string{service},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe service %s to be tagged by lock: %w", service, err)
+		}
+		if len(res.Services) == 0 {
+			return nil, fmt.Errorf("service %s to be tagged by lock is not found in cluster %s", service, cluster)
+		}
+		return lock.NewECSTagLocker(ecsSvc, aws.ToString(res.Services[0].ServiceArn)), nil
+	case config.LockBackendFile:
+		dir := lockConf.Dir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(projectRootPath, dir)
+		}
+		return lock.NewFileLocker(dir), nil
+	}
+	return nil, nil
+}
+
+// verifyLock returns an error if the lock is not held by the acquisition anymore, or is expired,
+// so that changes are not applied after another deploy has taken over the lock.
+// It does nothing if the lock is not configured.
+func verifyLock(ctx context.Context, locker lock.Locker, l *lock.Lock) error {
+	if locker == nil {
+		return nil
+	}
+	current, err := locker.Get(ctx, l.Key)
+	if err != nil {
+		return fmt.Errorf("failed to get deploy lock: %w", err)
+	}
+	if current == nil || current.ID != l.ID {
+		return fmt.Errorf("deploy lock %s is not held by %s anymore", l.Key, l.Owner)
+	}
+	if current.Expired(time.Now()) {
+		return fmt.Errorf("deploy lock %s expired at %s", l.Key, current.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// lockKey returns the key of the lock of the task in the target path, such as "/app1/production/task1".
+func lockKey(targetTaskPath, task string) string {
+	return path.Join(targetTaskPath, task)
+}
+
+// defaultLockOwner returns the description of the owner of locks, such as "user@host pid 123".
+func defaultLockOwner() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s@%s pid %d", name, host, os.Getpid())
+}
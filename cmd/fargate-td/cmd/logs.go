@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/kazz187/fargate-td/internal/awslogs"
+	"github.com/kazz187/fargate-td/internal/config"
+)
+
+// maxDescribeTasks is the maximum number of tasks of DescribeTasks.
+const maxDescribeTasks = 100
+
+func LogsCommand(ftr *FargateTdRunner) *cobra.Command {
+	r := &LogsRunner{
+		GenerateRunner: GenerateRunner{
+			VariablesRunner: *NewVariablesRunner(),
+		},
+	}
+	c := &cobra.Command{
+		Use:   `logs -p PATH -t TASK [--service NAME | --cron JOB] [--since 10m] [--follow]`,
+		Short: "Show logs of services and cron jobs",
+		Long: `Show logs of services and cron jobs
+
+Show awslogs logs of the tasks of the services in config.yml, interleaved by time.
+With --cron, the logs of the tasks launched by the cron job are shown instead.
+
+Run 'fargate-td logs -p PATH -t TASK [--service NAME | --cron JOB] [--since 10m] [--follow]
+
+    $ fargate-td logs -p app1/development -t task1 --since 30m --follow
+    $ fargate-td logs -p app1/development -t task1 --cron job1 --since 1h`,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	SetGenerateOptions(c, ftr, &r.GenerateRunner)
+	c.Flags().StringVar(&r.Service, "service", "", "service name (default: all services of the task)")
+	c.Flags().StringVar(&r.CronJob, "cron", "", "cron job name to show the logs of its tasks instead of the services")
+	c.MarkFlagsMutuallyExclusive("service", "cron")
+	c.Flags().DurationVar(&r.Since, "since", 10*time.Minute, "show logs since the duration ago")
+	c.Flags().BoolVarP(&r.Follow, "follow", "f", false, "follow new logs")
+	c.Flags().DurationVar(&r.Interval, "interval", 5*time.Second, "interval to fetch new logs with --follow")
+	c.Flags().BoolVar(&r.Rendered, "rendered", false, "use log configuration of the rendered task definition instead of the running one")
+	r.Command = c
+	return c
+}
+
+type LogsRunner struct {
+	GenerateRunner
+	Service  string
+	CronJob  string
+	Since    time.Duration
+	Follow   bool
+	Interval time.Duration
+	Rendered bool
+}
+
+// taskStream is a log stream of a task of a service or a cron job.
+type taskStream struct {
+	awslogs.Stream
+	Cluster string
+	// Label is the name of the service or the cron job
+	Label string
+}
+
+// taskSource is the tasks of a service, or the tasks which are launched by a cron job.
+type taskSource struct {
+	Cluster string
+	Label   string
+	list    *ecs.ListTasksInput
+	// group filters the tasks by the task group, unless it is empty
+	group string
+}
+
+func (r *LogsRunner) runE(c *cobra.Command, args []string) error {
+	ctx := context.Background()
+	deployConf, err := loadDeployConfig(r.ProjectRootPath, r.TargetTaskPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+	sources, err := r.taskSources(ctx, cfg, deployConf)
+	if err != nil {
+		return err
+	}
+	var renderedContainers []types.ContainerDefinition
+	if r.Rendered {
+		in, err := r.GenerateRunner.GenerateRegisterTaskDefinitionInput()
+		if err != nil {
+			return err
+		}
+		renderedContainers = in.ContainerDefinitions
+	}
+
+	ecsSvc := ecs.NewFromConfig(cfg)
+	clients := map[string]*cloudwatchlogs.Client{}
+	tdContainers := map[string][]types.ContainerDefinition{}
+	seen := map[string]time.Time{}
+	start := time.Now().Add(-r.Since)
+	for {
+		var streams []taskStream
+		for _, s := range sources {
+			ss, err := r.taskStreams(ctx, ecsSvc, s, renderedContainers, tdContainers)
+			if err != nil {
+				return err
+			}
+			streams = append(streams, ss...)
+		}
+		if len(streams) == 0 {
+			logrus.Warnln("no log stream of awslogs is found")
+		}
+
+		// FilterLogEvents is called for each log group
+		type groupKey struct{ region, group string }
+		groups := map[groupKey][]awslogs.Stream{}
+		labels := map[string]string{}
+		for _, s := range streams {
+			k := groupKey{s.Region, s.Group}
+			groups[k] = append(groups[k], s.Stream)
+			labels[s.TaskID+"/"+s.Container] = s.Label + "/" + s.Container
+		}
+		var events []awslogs.Event
+		for k, gs := range groups {
+			client, ok := clients[k.region]
+			if !ok {
+				client = awslogs.NewClient(cfg, k.region)
+				clients[k.region] = client
+			}
+			e, err := awslogs.FilterEvents(ctx, client, gs, start)
+			if err != nil {
+				return fmt.Errorf("failed to get log events of %s: %w", k.group, err)
+			}
+			events = append(events, e...)
+		}
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		})
+		for _, e := range events {
+			if _, ok := seen[e.ID]; ok {
+				continue
+			}
+			seen[e.ID] = e.Timestamp
+			label := fmt.Sprintf("[%s/%s]", labels[e.TaskID+"/"+e.Container], shortTaskID(e.TaskID))
+			fmt.Printf("%s %s %s\n", e.Timestamp.Format(time.RFC3339), au.Cyan(label), e.Message)
+			if e.Timestamp.After(start) {
+				// Events of the same millisecond can be fetched again, they are skipped by the ID
+				start = e.Timestamp
+			}
+		}
+		if !r.Follow {
+			return nil
+		}
+		for id, ts := range seen {
+			if ts.Before(start) {
+				delete(seen, id)
+			}
+		}
+		time.Sleep(r.Interval)
+	}
+}
+
+// taskSources returns the services of the task in config, or the cron job if --cron is specified.
+func (r *LogsRunner) taskSources(ctx context.Context, cfg aws.Config, deployConf *config.DeployConfig) ([]taskSource, error) {
+	var sources []taskSource
+	if r.CronJob != "" {
+		cweSvc := cloudwatchevents.NewFromConfig(cfg)
+		schSvc := scheduler.NewFromConfig(cfg)
+		for _, job := range deployConf.GetCronJobTaskConfigs(r.TaskName) {
+			if job.CronJob != r.CronJob {
+				continue
+			}
+			// Tasks of the cron job are found by the task definition family and the group of its target
+			target, err := getCronJobTaskTarget(ctx, cweSvc, schSvc, job)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the target of cron job %s [cluster: %s]: %w", job.CronJob, job.Cluster, err)
+			}
+			group := target.Group
+			if group == "" {
+				group = "family:" + target.Family()
+			}
+			sources = append(sources, taskSource{
+				Cluster: job.Cluster,
+				Label:   job.CronJob,
+				list:    &ecs.ListTasksInput{Cluster: aws.String(job.Cluster), Family: aws.String(target.Family())},
+				group:   group,
+			})
+		}
+		if len(sources) == 0 {
+			return nil, fmt.Errorf("cron job %s of task %s is not found in config", r.CronJob, r.TaskName)
+		}
+		return sources, nil
+	}
+	for _, s := range deployConf.GetServiceTaskConfigs(r.TaskName) {
+		if r.Service == "" || s.Service == r.Service {
+			sources = append(sources, taskSource{
+				Cluster: s.Cluster,
+				Label:   s.Service,
+				list:    &ecs.ListTasksInput{Cluster: aws.String(s.Cluster), ServiceName: aws.String(s.Service)},
+			})
+		}
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("services of task %s are not found in config", r.TaskName)
+	}
+	return sources, nil
+}
+
+// taskStreams returns the log streams of the running and recently stopped tasks of the source.
+func (r *LogsRunner) taskStreams(ctx context.Context, ecsSvc *ecs.Client, s taskSource, renderedContainers []types.ContainerDefinition, tdContainers map[string][]types.ContainerDefinition) ([]taskStream, error) {
+	var taskArns []string
+	for _, status := range []types.DesiredStatus{types.DesiredStatusRunning, types.DesiredStatusStopped} {
+		in := *s.list
+		in.DesiredStatus = status
+		p := ecs.NewListTasksPaginator(ecsSvc, &in)
+		for p.HasMorePages() {
+			out, err := p.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list tasks of %s: %w", s.Label, err)
+			}
+			taskArns = append(taskArns, out.TaskArns...)
+		}
+	}
+
+	var streams []taskStream
+	for i := 0; i < len(taskArns); i += maxDescribeTasks {
+		end := min(i+maxDescribeTasks, len(taskArns))
+		out, err := ecsSvc.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: &s.Cluster,
+			Tasks:   taskArns[i:end],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe tasks of %s: %w", s.Label, err)
+		}
+		for _, task := range out.Tasks {
+			if s.group != "" && aws.ToString(task.Group) != s.group {
+				continue
+			}
+			containers := renderedContainers
+			if containers == nil {
+				tdArn := aws.ToString(task.TaskDefinitionArn)
+				var ok bool
+				containers, ok = tdContainers[tdArn]
+				if !ok {
+					tdRes, err := ecsSvc.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+						TaskDefinition: &tdArn,
+					})
+					if err != nil {
+						return nil, fmt.Errorf("failed to describe task definition %s: %w", tdArn, err)
+					}
+					containers = tdRes.TaskDefinition.ContainerDefinitions
+					tdContainers[tdArn] = containers
+				}
+			}
+			for _, stream := range awslogs.TaskStreams(containers, aws.ToString(task.TaskArn)) {
+				streams = append(streams, taskStream{
+					Stream:  stream,
+					Cluster: s.Cluster,
+					Label:   s.Label,
+				})
+			}
+		}
+	}
+	return streams, nil
+}
+
+func shortTaskID(taskID string) string {
+	if len(taskID) > 8 {
+		return taskID[:8]
+	}
+	return taskID
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kazz187/fargate-td/internal/report"
+)
+
+// ReportOptions are the options to write the report of the diff.
+type ReportOptions struct {
+	DiffFormat string
+	ReportFile string
+}
+
+func SetReportOptions(c *cobra.Command, o *ReportOptions) {
+	c.Flags().StringVar(&o.DiffFormat, "diff-format", report.FormatText, "format of the report (text|markdown|json)")
+	c.Flags().StringVar(&o.ReportFile, "report-file", "", "file to write the report to")
+}
+
+func (o *ReportOptions) validate() error {
+	switch o.DiffFormat {
+	case report.FormatText:
+	case report.FormatMarkdown, report.FormatJSON:
+		if o.ReportFile == "" {
+			// The report is not mixed with the output of the command
+			return fmt.Errorf("--report-file is required with --diff-format %s", o.DiffFormat)
+		}
+	default:
+		return fmt.Errorf("invalid diff format %s (%s)", o.DiffFormat, strings.Join([]string{report.FormatText, report.FormatMarkdown, report.FormatJSON}, "|"))
+	}
+	return nil
+}
+
+// write writes the report to the report file, if it is specified.
+func (o *ReportOptions) write(rep *report.Report) error {
+	if o.ReportFile == "" {
+		return nil
+	}
+	if err := rep.WriteFile(o.ReportFile, o.DiffFormat); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote report [%s]\n", o.ReportFile)
+	return nil
+}
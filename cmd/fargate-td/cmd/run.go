@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/kazz187/fargate-td/internal/awslogs"
+	"github.com/kazz187/fargate-td/internal/config"
+	"github.com/kazz187/fargate-td/pkg/watch"
+)
+
+const runTaskStartedBy = "fargate-td"
+
+func RunCommand(ftr *FargateTdRunner) *cobra.Command {
+	r := &RunRunner{
+		GenerateRunner: GenerateRunner{
+			VariablesRunner: *NewVariablesRunner(),
+		},
+	}
+	c := &cobra.Command{
+		Use:   `run -p PATH -t TASK -v"Key=Value" [-- COMMAND [ARGS...]]`,
+		Short: "Run one-off task",
+		Long: `Run one-off task
+
+Register the task definition and run it as a one-off task with the command.
+The log of the task is streamed, and the exit code of the essential container is returned.
+
+Run 'fargate-td run -p PATH -t TASK -v"Key=Value" -- COMMAND [ARGS...]
+
+    $ fargate-td run -p app1/production -t migrate -- bundle exec rake db:migrate`,
+		PreRunE:      r.preRunE,
+		RunE:         r.runE,
+		SilenceUsage: true,
+	}
+	SetGenerateOptions(c, ftr, &r.GenerateRunner)
+	c.Flags().StringVar(&r.Cluster, "cluster", "", "cluster name (default: cluster of runTasks in config)")
+	c.Flags().StringVar(&r.Service, "service", "", "service name to copy the network configuration from")
+	c.Flags().StringVar(&r.Container, "container", "", "container name to override the command (default: first essential container)")
+	c.Flags().DurationVar(&r.Interval, "interval", 5*time.Second, "interval to check the task status")
+	c.Flags().DurationVar(&r.Timeout, "timeout", time.Hour, "timeout of waiting for the task to stop (0 means no timeout)")
+	r.Command = c
+	return c
+}
+
+type RunRunner struct {
+	GenerateRunner
+	Cluster   string
+	Service   string
+	Container string
+	Interval  time.Duration
+	Timeout   time.Duration
+}
+
+func (r *RunRunner) preRunE(c *cobra.Command, args []string) error {
+	if err := r.GenerateRunner.preRunE(c, args); err != nil {
+		return err
+	}
+	if r.Interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	if r.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	return nil
+}
+
+func (r *RunRunner) runE(c *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	deployConf, err := loadDeployConfig(r.ProjectRootPath, r.TargetTaskPath)
+	if err != nil {
+		return err
+	}
+	runConf, err := r.runTaskConfig(deployConf)
+	if err != nil {
+		return err
+	}
+
+	in, err := r.GenerateRunner.GenerateRegisterTaskDefinitionInput()
+	if err != nil {
+		return err
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+	ecsSvc := ecs.NewFromConfig(cfg)
+	tdRes, err := ecsSvc.RegisterTaskDefinition(ctx, in)
+	if err != nil {
+		return fmt.Errorf("failed to register task definition: %w", err)
+	}
+	td := tdRes.TaskDefinition
+	fmt.Printf("Registered task definition [%s]\n", *td.TaskDefinitionArn)
+
+	runIn, err := buildRunTaskInput(ctx, ecsSvc, runConf, *td.TaskDefinitionArn)
+	if err != nil {
+		return err
+	}
+	container := r.Container
+	if container == "" {
+		container = essentialContainerName(td.ContainerDefinitions)
+	}
+	if len(args) != 0 {
+		runIn.Overrides = &types.TaskOverride{
+			ContainerOverrides: []types.ContainerOverride{
+				{
+					Name:    &container,
+					Command: args,
+				},
+			},
+		}
+	}
+	runRes, err := ecsSvc.RunTask(ctx, runIn)
+	if err != nil {
+		return fmt.Errorf("failed to run task: %w", err)
+	}
+	if len(runRes.Failures) != 0 {
+		var reasons []string
+		for _, f := range runRes.Failures {
+			reasons = append(reasons, aws.ToString(f.Reason))
+		}
+		return fmt.Errorf("failed to run task: %s", strings.Join(reasons, ", "))
+	}
+	task := runRes.Tasks[0]
+	fmt.Printf("Run task [cluster: %s, task: %s]\n", runConf.Cluster, *task.TaskArn)
+
+	return r.waitTask(ctx, cfg, ecsSvc, runConf.Cluster, *task.TaskArn, td.ContainerDefinitions)
+}
+
+func (r *RunRunner) runTaskConfig(deployConf *config.DeployConfig) (config.RunTaskConfig, error) {
+	var runConfs []config.RunTaskConfig
+	for _, rc := range deployConf.GetRunTaskConfigs(r.TaskName) {
+		if r.Cluster == "" || rc.Cluster == r.Cluster {
+			runConfs = append(runConfs, rc)
+		}
+	}
+	var runConf config.RunTaskConfig
+	switch len(runConfs) {
+	case 0:
+		if r.Cluster == "" || r.Service == "" {
+			return runConf, fmt.Errorf("runTasks of task %s is not found in config, specify --cluster and --service", r.TaskName)
+		}
+	case 1:
+		runConf = runConfs[0]
+	default:
+		return runConf, fmt.Errorf("multiple runTasks of task %s are found in config, specify --cluster", r.TaskName)
+	}
+	if r.Cluster != "" {
+		runConf.Cluster = r.Cluster
+	}
+	if r.Service != "" {
+		runConf.Service = r.Service
+		runConf.NetworkConfiguration = nil
+	}
+	if runConf.Service == "" && runConf.NetworkConfiguration == nil {
+		return runConf, fmt.Errorf("service or networkConfiguration is required in runTasks of task %s", r.TaskName)
+	}
+	return runConf, nil
+}
+
+func buildRunTaskInput(ctx context.Context, ecsSvc *ecs.Client, runConf config.RunTaskConfig, tdArn string) (*ecs.RunTaskInput, error) {
+	in := &ecs.RunTaskInput{
+		Cluster:        &runConf.Cluster,
+		TaskDefinition: &tdArn,
+		Count:          aws.Int32(1),
+		StartedBy:      aws.String(runTaskStartedBy),
+	}
+	if runConf.Service != "" {
+		svcRes, err := ecsSvc.DescribeServices(ctx, &ecs.DescribeServicesInput{
+			Cluster:  &runConf.Cluster,
+			Services: []string{runConf.Service},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe service: %w", err)
+		}
+		if len(svcRes.Services) == 0 {
+			return nil, fmt.Errorf("service %s is not found in cluster %s", runConf.Service, runConf.Cluster)
+		}
+		s := svcRes.Services[0]
+		in.NetworkConfiguration = s.NetworkConfiguration
+		in.LaunchType = s.LaunchType
+		in.CapacityProviderStrategy = s.CapacityProviderStrategy
+		in.PlatformVersion = s.PlatformVersion
+	}
+	if nc := runConf.NetworkConfiguration; nc != nil {
+		in.NetworkConfiguration = &types.NetworkConfiguration{
+			AwsvpcConfiguration: &types.AwsVpcConfiguration{
+				Subnets:        nc.Subnets,
+				SecurityGroups: nc.SecurityGroups,
+				AssignPublicIp: types.AssignPublicIp(nc.AssignPublicIp),
+			},
+		}
+	}
+	if runConf.LaunchType != "" {
+		in.LaunchType = types.LaunchType(runConf.LaunchType)
+		in.CapacityProviderStrategy = nil
+	}
+	if runConf.PlatformVersion != "" {
+		in.PlatformVersion = aws.String(runConf.PlatformVersion)
+	}
+	return in, nil
+}
+
+// waitTask streams the log of the task until it stops, and returns ExitError if the essential container failed.
+func (r *RunRunner) waitTask(ctx context.Context, cfg aws.Config, ecsSvc *ecs.Client, cluster string, taskArn string, containers []types.ContainerDefinition) error {
+	var tailers []*awslogs.Tailer
+	clients := map[string]*cloudwatchlogs.Client{}
+	for _, s := range awslogs.TaskStreams(containers, taskArn) {
+		client, ok := clients[s.Region]
+		if !ok {
+			client = awslogs.NewClient(cfg, s.Region)
+			clients[s.Region] = client
+		}
+		tailers = append(tailers, awslogs.NewTailer(client, s))
+	}
+	if len(tailers) == 0 {
+		logrus.Warnln("no container uses awslogs log driver, the log of the task is not shown")
+	}
+	printLogs := func() {
+		for _, t := range tailers {
+			events, err := t.Fetch(ctx)
+			if err != nil {
+				logrus.Warnf("failed to get log events: %s", err)
+			}
+			for _, e := range events {
+				fmt.Printf("[%s] %s\n", e.Container, e.Message)
+			}
+		}
+	}
+
+	w := watch.NewTaskWatch(cluster, []string{taskArn}, r.Interval, r.Timeout, watch.WithECS(ecsSvc))
+	go w.Start(ctx)
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		var result watch.TaskResult
+		select {
+		case result = <-w.Results:
+		case <-ticker.C:
+			printLogs()
+			continue
+		}
+		switch result.Status {
+		case watch.Progress:
+			logrus.Infof("task status is %s", result.LastStatus)
+		case watch.TaskSucceeded, watch.TaskFailed:
+			// Wait for the last log events to be delivered
+			<-ticker.C
+			printLogs()
+			return taskExitError(result)
+		case watch.Canceled:
+			// 130 is the exit code of the process which is interrupted
+			return &ExitError{
+				Code:    130,
+				Message: fmt.Sprintf("run is canceled, task %s is not stopped", awslogs.TaskID(taskArn)),
+			}
+		case watch.Timeout:
+			return &ExitError{
+				Code:    1,
+				Message: fmt.Sprintf("task %s is not stopped in %s (last status: %s)", awslogs.TaskID(taskArn), r.Timeout, result.LastStatus),
+			}
+		default:
+			return result.Error
+		}
+	}
+}
+
+// taskExitError returns ExitError with the exit code of the failed essential container.
+func taskExitError(result watch.TaskResult) error {
+	if result.Status == watch.TaskSucceeded {
+		fmt.Printf("Task succeeded [task: %s]\n", result.TaskArn)
+		return nil
+	}
+	code := 1
+	for _, c := range result.Stopped.Containers {
+		if !c.Essential {
+			continue
+		}
+		if c.ExitCode == nil || *c.ExitCode != 0 {
+			// A container which is stopped without exit code fails with 1
+			if c.ExitCode != nil {
+				code = int(*c.ExitCode)
+			}
+			break
+		}
+	}
+	return &ExitError{
+		Code:    code,
+		Message: result.Error.Error(),
+	}
+}
+
+func essentialContainerName(containers []types.ContainerDefinition) string {
+	for _, c := range containers {
+		if c.Essential == nil || *c.Essential {
+			return aws.ToString(c.Name)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kazz187/fargate-td/internal/config"
+	"github.com/kazz187/fargate-td/internal/cron"
+)
+
+func ScheduleCommand(ftr *FargateTdRunner) *cobra.Command {
+	r := &ScheduleRunner{}
+	c := &cobra.Command{
+		Use:   `schedule -p PATH -t TASK [-n COUNT] [--timezone TZ]`,
+		Short: "Show next run times of cron jobs",
+		Long: `Show next run times of cron jobs
+
+Run 'fargate-td schedule -p PATH -t TASK [-n COUNT] [--timezone TZ]
+
+    $ fargate-td schedule -p app1/development -t task1 -n 10 --timezone Asia/Tokyo`,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	SetCronOptions(c, ftr, &r.CronRunner)
+	c.Flags().IntVarP(&r.Count, "count", "n", 5, "number of run times to show")
+	c.Flags().StringVar(&r.Timezone, "timezone", "", "time zone to show run times in (default: local time zone)")
+	r.Command = c
+	return c
+}
+
+type ScheduleRunner struct {
+	CronRunner
+	Count    int
+	Timezone string
+}
+
+func (r *ScheduleRunner) runE(c *cobra.Command, args []string) error {
+	displayLoc := time.Local
+	if r.Timezone != "" {
+		var err error
+		displayLoc, err = time.LoadLocation(r.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid time zone %s: %w", r.Timezone, err)
+		}
+	}
+	cronJobs, err := r.CronJobs()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, job := range cronJobs {
+		fmt.Printf("Schedule [cluster: %s, cronJob: %s, cron: %s]\n", job.Cluster, job.CronJob, job.Cron)
+		runTimes, err := nextRunTimes(job, now, r.Count)
+		if err != nil {
+			return err
+		}
+		for _, t := range runTimes {
+			fmt.Printf("  %s\n", t.In(displayLoc).Format("2006-01-02 15:04:05 MST (Mon)"))
+		}
+		if len(runTimes) == 0 {
+			fmt.Println("  No upcoming run")
+		}
+	}
+	return nil
+}
+
+// nextRunTimes returns up to count run times of the cron job after now, within its start and end dates.
+func nextRunTimes(job config.CronJobTaskConfig, now time.Time, count int) ([]time.Time, error) {
+	s, err := cron.Parse(job.Cron)
+	if err != nil {
+		return nil, err
+	}
+	// Rules are evaluated in UTC, schedules are evaluated in their time zone
+	loc := time.UTC
+	if job.UsesScheduler() && job.Timezone != "" {
+		loc, err = time.LoadLocation(job.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone %s of cron job %s: %w", job.Timezone, job.CronJob, err)
+		}
+	}
+	t := now.In(loc)
+	if job.StartDate != nil && job.StartDate.After(t) {
+		t = job.StartDate.In(loc).Add(-time.Minute)
+	}
+	var runTimes []time.Time
+	for len(runTimes) < count {
+		t = s.Next(t)
+		if t.IsZero() || (job.EndDate != nil && t.After(*job.EndDate)) {
+			break
+		}
+		runTimes = append(runTimes, t)
+	}
+	return runTimes, nil
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cwetypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchevents/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	schtypes "github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kazz187/fargate-td/internal/config"
+	"github.com/kazz187/fargate-td/internal/redact"
+	"github.com/kazz187/fargate-td/internal/report"
+)
+
+// planSchedule prints and returns the update of the schedule of the cron job, and adds it to the report target.
+func planSchedule(ctx context.Context, ecsSvc *ecs.Client, schSvc *scheduler.Client, job config.CronJobTaskConfig, clusterArn string, newTd *types.TaskDefinition, target *report.Target, redactor *redact.Redactor) (*cronJobPlan, error) {
+	fmt.Printf("Diff [cluster: %s, schedule: %s]\n", job.Cluster, job.CronJob)
+	plan := &cronJobPlan{conf: job}
+	newTdArn := aws.ToString(newTd.TaskDefinitionArn)
+	current, err := getSchedule(ctx, schSvc, job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	if current == nil {
+		in, err := buildScheduleInput(job, nil, clusterArn, newTdArn)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println("Schedule is not found, it will be created")
+		target.NewTaskDefinitionArn = newTdArn
+		target.AddNote(report.StatusCreated, "schedule is not found, it will be created", "")
+		plan.createSchedule = &scheduler.CreateScheduleInput{
+			Name:                       in.Name,
+			GroupName:                  in.GroupName,
+			ScheduleExpression:         in.ScheduleExpression,
+			ScheduleExpressionTimezone: in.ScheduleExpressionTimezone,
+			StartDate:                  in.StartDate,
+			EndDate:                    in.EndDate,
+			FlexibleTimeWindow:         in.FlexibleTimeWindow,
+			State:                      in.State,
+			Target:                     in.Target,
+		}
+		return plan, nil
+	}
+	if current.Target == nil || current.Target.EcsParameters == nil {
+		return nil, fmt.Errorf("schedule %s does not have ECS target", job.CronJob)
+	}
+	tdArn := *current.Target.EcsParameters.TaskDefinitionArn
+	changed, err := diffTaskDefinition(ctx, ecsSvc, tdArn, newTd, target, redactor)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the current revision if the task definition is not changed
+	if changed {
+		tdArn = newTdArn
+	}
+	in, err := buildScheduleInput(job, current, clusterArn, tdArn)
+	if err != nil {
+		return nil, err
+	}
+	if cmp.Equal(scheduleInputFromOutput(current), in, cronJobComparer(nil)) {
+		return plan, nil
+	}
+	message := "schedule differs from config, it will be updated"
+	diff := cmp.Diff(scheduleInputFromOutput(current), in, cronJobComparer(redactor))
+	fmt.Println(upperFirst(message))
+	fmt.Println("```")
+	displayColorDiff(diff)
+	fmt.Println("```")
+	target.AddNote(report.StatusChanged, message, diff)
+	plan.updateSchedule = in
+	return plan, nil
+}
+
+// getSchedule returns the schedule of the cron job, or nil if it does not exist.
+func getSchedule(ctx context.Context, schSvc *scheduler.Client, job config.CronJobTaskConfig) (*scheduler.GetScheduleOutput, error) {
+	schedule, err := schSvc.GetSchedule(ctx, &scheduler.GetScheduleInput{
+		Name:      &job.CronJob,
+		GroupName: &job.ScheduleGroup,
+	})
+	if err != nil {
+		var notFound *schtypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// scheduleState returns the schedule state of the cron job state, or defaultState if the state is not managed.
+func scheduleState(state string, defaultState schtypes.ScheduleState) schtypes.ScheduleState {
+	switch state {
+	case config.CronJobStateEnabled:
+		return schtypes.ScheduleStateEnabled
+	case config.CronJobStateDisabled:
+		return schtypes.ScheduleStateDisabled
+	}
+	return defaultState
+}
+
+// defaultScheduleTimezone is the time zone of the schedule expression if it is not set, which rules are evaluated in as well.
+const defaultScheduleTimezone = "UTC"
+
+func scheduleInputFromOutput(out *scheduler.GetScheduleOutput) *scheduler.UpdateScheduleInput {
+	timezone := out.ScheduleExpressionTimezone
+	if timezone == nil {
+		timezone = aws.String(defaultScheduleTimezone)
+	}
+	return &scheduler.UpdateScheduleInput{
+		Name:                       out.Name,
+		GroupName:                  out.GroupName,
+		Description:                out.Description,
+		ScheduleExpression:         out.ScheduleExpression,
+		ScheduleExpressionTimezone: timezone,
+		StartDate:                  out.StartDate,
+		EndDate:                    out.EndDate,
+		FlexibleTimeWindow:         out.FlexibleTimeWindow,
+		ActionAfterCompletion:      out.ActionAfterCompletion,
+		KmsKeyArn:                  out.KmsKeyArn,
+		State:                      out.State,
+		Target:                     out.Target,
+	}
+}
+
+// buildScheduleInput returns the schedule described by the cron job config.
+// The target is built by buildCronJobTarget, so rules and schedules describe the same ECS target.
+func buildScheduleInput(taskConf config.CronJobTaskConfig, current *scheduler.GetScheduleOutput, clusterArn string, tdArn string) (*scheduler.UpdateScheduleInput, error) {
+	var currentTarget cwetypes.Target
+	if current != nil && current.Target != nil {
+		currentTarget = ruleTargetFromScheduleTarget(current.Target)
+		// The dead-letter config and the retry policy of schedules are described by config
+		currentTarget.DeadLetterConfig, currentTarget.RetryPolicy = nil, nil
+	}
+	ruleTarget, err := buildCronJobTarget(taskConf, currentTarget, clusterArn, tdArn)
+	if err != nil {
+		return nil, err
+	}
+	return newScheduleInput(taskConf, current, scheduleTargetFromRuleTarget(&ruleTarget)), nil
+}
+
+// newScheduleInput returns the schedule of the target with the fields described by the cron job config.
+// Fields which the config does not describe, such as the description, are taken over from the current schedule.
+func newScheduleInput(taskConf config.CronJobTaskConfig, current *scheduler.GetScheduleOutput, target *schtypes.Target) *scheduler.UpdateScheduleInput {
+	in := &scheduler.UpdateScheduleInput{
+		State: schtypes.ScheduleStateEnabled,
+	}
+	if current != nil {
+		in.Description = current.Description
+		in.ActionAfterCompletion = current.ActionAfterCompletion
+		in.KmsKeyArn = current.KmsKeyArn
+		in.State = current.State
+	}
+	in.Name = aws.String(taskConf.CronJob)
+	in.GroupName = aws.String(taskConf.ScheduleGroup)
+	in.ScheduleExpression = aws.String(taskConf.Cron)
+	in.State = scheduleState(taskConf.State, in.State)
+	in.ScheduleExpressionTimezone = aws.String(defaultScheduleTimezone)
+	if taskConf.Timezone != "" {
+		in.ScheduleExpressionTimezone = aws.String(taskConf.Timezone)
+	}
+	in.StartDate = taskConf.StartDate
+	in.EndDate = taskConf.EndDate
+	in.FlexibleTimeWindow = &schtypes.FlexibleTimeWindow{
+		Mode: schtypes.FlexibleTimeWindowModeOff,
+	}
+	if taskConf.FlexibleTimeWindow != 0 {
+		in.FlexibleTimeWindow = &schtypes.FlexibleTimeWindow{
+			Mode:                   schtypes.FlexibleTimeWindowModeFlexible,
+			MaximumWindowInMinutes: aws.Int32(taskConf.FlexibleTimeWindow),
+		}
+	}
+	if taskConf.DeadLetterQueue != "" {
+		target.DeadLetterConfig = &schtypes.DeadLetterConfig{
+			Arn: aws.String(taskConf.DeadLetterQueue),
+		}
+	}
+	if rp := taskConf.RetryPolicy; rp != nil {
+		target.RetryPolicy = &schtypes.RetryPolicy{
+			MaximumRetryAttempts:     rp.MaximumRetryAttempts,
+			MaximumEventAgeInSeconds: rp.MaximumEventAgeInSeconds,
+		}
+	}
+	in.Target = target
+	return in
+}
@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchevents"
+	cwetypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchevents/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/kazz187/fargate-td/internal/config"
+	"github.com/kazz187/fargate-td/internal/redact"
+	"github.com/kazz187/fargate-td/internal/taskdef"
+)
+
+// maxDescribeServices is the maximum number of services of DescribeServices.
+const maxDescribeServices = 10
+
+const statusTimeFormat = "2006-01-02 15:04:05 MST"
+
+func StatusCommand(ftr *FargateTdRunner) *cobra.Command {
+	r := &StatusRunner{
+		GenerateRunner: GenerateRunner{
+			VariablesRunner: *NewVariablesRunner(),
+		},
+	}
+	c := &cobra.Command{
+		Use:   `status -p PATH -t TASK -v"Key=Value"`,
+		Short: "Show status of services and cron jobs",
+		Long: `Show status of services and cron jobs
+
+Show the services and cron jobs of the task in config.yml, and whether they run
+the task definition which is rendered from the repository.
+
+Run 'fargate-td status -p PATH -t TASK -v"Key=Value"
+
+    $ fargate-td status -p app1/production -t task1 -v"Version=0.0.1"`,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	SetGenerateOptions(c, ftr, &r.GenerateRunner)
+	c.Flags().IntVar(&r.Events, "events", 5, "number of service events to show")
+	r.Command = c
+	return c
+}
+
+type StatusRunner struct {
+	GenerateRunner
+	Events int
+}
+
+func (r *StatusRunner) runE(c *cobra.Command, args []string) error {
+	ctx := context.Background()
+	deployConf, err := loadDeployConfig(r.ProjectRootPath, r.TargetTaskPath)
+	if err != nil {
+		return err
+	}
+	rendered, err := r.GenerateRunner.GenerateRegisterTaskDefinitionInput()
+	if err != nil {
+		// The status is still useful without the comparison
+		logrus.Warnf("failed to render task definition, revisions are not compared: %s", err)
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+	ecsSvc := ecs.NewFromConfig(cfg)
+	cweSvc := cloudwatchevents.NewFromConfig(cfg)
+	schSvc := scheduler.NewFromConfig(cfg)
+	// Only whether the revisions differ is shown, so values are not masked
+	checker := newRevisionChecker(ecsSvc, rendered, nil)
+
+	servicesMap := deployConf.GetServicesMapGroupByCluster(r.TaskName)
+	clusters := make([]string, 0, len(servicesMap))
+	for cluster := range servicesMap {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+	for _, cluster := range clusters {
+		if err := r.printServices(ctx, ecsSvc, checker, cluster, servicesMap[cluster]); err != nil {
+			return err
+		}
+	}
+	for _, job := range deployConf.GetCronJobTaskConfigs(r.TaskName) {
+		if err := printCronJobStatus(ctx, cweSvc, schSvc, checker, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *StatusRunner) printServices(ctx context.Context, ecsSvc *ecs.Client, checker *revisionChecker, cluster string, services []string) error {
+	for i := 0; i < len(services); i += maxDescribeServices {
+		end := min(i+maxDescribeServices, len(services))
+		svcRes, err := ecsSvc.DescribeServices(ctx, &ecs.DescribeServicesInput{
+			Cluster:  &cluster,
+			Services: services[i:end],
+		})
+		if err != nil {
+			return fmt.Errorf("failed to describe services: %w", err)
+		}
+		found := map[string]types.Service{}
+		for _, s := range svcRes.Services {
+			found[aws.ToString(s.ServiceName)] = s
+		}
+		for _, name := range services[i:end] {
+			fmt.Printf("Service [cluster: %s, service: %s]\n", cluster, name)
+			s, ok := found[name]
+			if !ok {
+				fmt.Println("  Not found")
+				continue
+			}
+			tdArn := aws.ToString(s.TaskDefinition)
+			fmt.Printf("  Status: %s\n", aws.ToString(s.Status))
+			fmt.Printf("  Task definition: %s (%s)\n", taskdef.Revision(tdArn), checker.check(ctx, tdArn))
+			fmt.Printf("  Tasks: desired %d, running %d, pending %d\n", s.DesiredCount, s.RunningCount, s.PendingCount)
+			fmt.Println("  Deployments:")
+			for _, d := range s.Deployments {
+				fmt.Printf("    %s %s %s: desired %d, running %d, pending %d, failed %d (updated at %s)\n",
+					aws.ToString(d.Status),
+					taskdef.Revision(aws.ToString(d.TaskDefinition)),
+					rolloutState(d),
+					d.DesiredCount, d.RunningCount, d.PendingCount, d.FailedTasks,
+					formatStatusTime(d.UpdatedAt),
+				)
+				if d.RolloutStateReason != nil {
+					fmt.Printf("      %s\n", *d.RolloutStateReason)
+				}
+			}
+			fmt.Println("  Events:")
+			for j, e := range s.Events {
+				if j >= r.Events {
+					break
+				}
+				fmt.Printf("    %s %s\n", formatStatusTime(e.CreatedAt), aws.ToString(e.Message))
+			}
+		}
+	}
+	return nil
+}
+
+func printCronJobStatus(ctx context.Context, cweSvc *cloudwatchevents.Client, schSvc *scheduler.Client, checker *revisionChecker, job config.CronJobTaskConfig) error {
+	fmt.Printf("Cron job [cluster: %s, cronJob: %s]\n", job.Cluster, job.CronJob)
+	if job.UsesScheduler() {
+		schedule, err := getSchedule(ctx, schSvc, job)
+		if err != nil {
+			return fmt.Errorf("failed to get schedule: %w", err)
+		}
+		if schedule == nil {
+			fmt.Println("  Schedule is not found")
+			return nil
+		}
+		fmt.Printf("  Schedule: %s (EventBridge Scheduler, group: %s)\n", aws.ToString(schedule.ScheduleExpression), aws.ToString(schedule.GroupName))
+		if schedule.ScheduleExpressionTimezone != nil {
+			fmt.Printf("  Time zone: %s\n", *schedule.ScheduleExpressionTimezone)
+		}
+		fmt.Printf("  State: %s\n", schedule.State)
+		if schedule.Target != nil && schedule.Target.EcsParameters != nil {
+			tdArn := aws.ToString(schedule.Target.EcsParameters.TaskDefinitionArn)
+			fmt.Printf("  Task definition: %s (%s)\n", taskdef.Revision(tdArn), checker.check(ctx, tdArn))
+		}
+		return nil
+	}
+
+	rule, err := cweSvc.DescribeRule(ctx, &cloudwatchevents.DescribeRuleInput{
+		Name: &job.CronJob,
+	})
+	if err != nil {
+		var notFound *cwetypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			fmt.Println("  Rule is not found")
+			return nil
+		}
+		return fmt.Errorf("failed to get rule: %w", err)
+	}
+	fmt.Printf("  Schedule: %s (CloudWatch Events)\n", aws.ToString(rule.ScheduleExpression))
+	fmt.Printf("  State: %s\n", rule.State)
+	targets, err := cweSvc.ListTargetsByRule(ctx, &cloudwatchevents.ListTargetsByRuleInput{
+		Rule: rule.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get targets: %w", err)
+	}
+	for _, target := range targets.Targets {
+		if target.EcsParameters == nil {
+			continue
+		}
+		tdArn := aws.ToString(target.EcsParameters.TaskDefinitionArn)
+		fmt.Printf("  Task definition: %s (%s, target: %s)\n", taskdef.Revision(tdArn), checker.check(ctx, tdArn), aws.ToString(target.Id))
+	}
+	return nil
+}
+
+// revisionChecker checks whether task definitions are the same as the rendered one.
+type revisionChecker struct {
+	ecsSvc   *ecs.Client
+	rendered *ecs.RegisterTaskDefinitionInput
+	redactor *redact.Redactor
+	changes  map[string]*taskdef.Change
+}
+
+func newRevisionChecker(ecsSvc *ecs.Client, rendered *ecs.RegisterTaskDefinitionInput, redactor *redact.Redactor) *revisionChecker {
+	return &revisionChecker{
+		ecsSvc:   ecsSvc,
+		rendered: redactor.TaskDefinition(rendered),
+		redactor: redactor,
+		changes:  map[string]*taskdef.Change{},
+	}
+}
+
+// check returns the description of whether the task definition is the same as the rendered one.
+func (rc *revisionChecker) check(ctx context.Context, tdArn string) string {
+	if rc.rendered == nil {
+		return "not compared"
+	}
+	change, err := rc.change(ctx, tdArn)
+	if err != nil {
+		logrus.Warnln(err)
+		return "unknown"
+	}
+	if change != nil {
+		return au.Yellow("differs from repository").String()
+	}
+	return au.Green("up-to-date").String()
+}
+
+// change returns the change from the task definition to the rendered one, or nil if they are equal.
+func (rc *revisionChecker) change(ctx context.Context, tdArn string) (*taskdef.Change, error) {
+	if change, ok := rc.changes[tdArn]; ok {
+		return change, nil
+	}
+	tdRes, err := rc.ecsSvc.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: &tdArn,
+		Include:        []types.TaskDefinitionField{types.TaskDefinitionFieldTags},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe task definition %s: %w", tdArn, err)
+	}
+	change, err := taskdef.Compare(
+		rc.redactor.TaskDefinition(taskdef.FromTaskDefinition(tdRes.TaskDefinition, tdRes.Tags)),
+		rc.rendered,
+		taskdef.Revision(tdArn),
+		"repository",
+	)
+	if err != nil {
+		return nil, err
+	}
+	rc.changes[tdArn] = change
+	return change, nil
+}
+
+func rolloutState(d types.Deployment) string {
+	if d.RolloutState == "" {
+		// Deployments which are not managed by the ECS deployment controller have no rollout state
+		return "-"
+	}
+	return string(d.RolloutState)
+}
+
+func formatStatusTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(statusTimeFormat)
+}
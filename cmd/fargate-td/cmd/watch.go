@@ -1,15 +1,27 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/kazz187/fargate-td/pkg/watch"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/sirupsen/logrus"
 
-	"github.com/kazz187/fargate-td/internal/config"
+	"github.com/kazz187/fargate-td/internal/awslogs"
+	"github.com/kazz187/fargate-td/internal/ratelimit"
+	"github.com/kazz187/fargate-td/internal/report"
+	"github.com/kazz187/fargate-td/pkg/watch"
 
 	"github.com/spf13/cobra"
 )
@@ -17,15 +29,20 @@ import (
 func WatchCommand(ftr *FargateTdRunner) *cobra.Command {
 	r := &WatchRunner{}
 	c := &cobra.Command{
-		Use:   `watch -p PATH -t TASK -v"Key=Value"`,
+		Use:   `watch -p PATH -t TASK [--cron JOB --next]`,
 		Short: "Watch deployment status",
 		Long: `Watch deployment status
 
 Run 'fargate-td watch -p PATH -t TASK
 
-    $ fargate-td watch -p app1/development -t task1`,
-		PreRunE: r.preRunE,
-		RunE:    r.runE,
+    $ fargate-td watch -p app1/development -t task1
+
+Wait for the next run of a cron job, and watch its tasks until they are stopped
+
+    $ fargate-td watch -p app1/development -t task1 --cron job1 --next`,
+		PreRunE:      r.preRunE,
+		RunE:         r.runE,
+		SilenceUsage: true,
 	}
 	SetWatchOptions(c, ftr, r)
 	r.Command = c
@@ -38,6 +55,13 @@ func SetWatchOptions(c *cobra.Command, ftr *FargateTdRunner, r *WatchRunner) {
 	c.Flags().StringVarP(&r.TargetTaskPath, "path", "p", "", "watch target path")
 	_ = c.MarkFlagRequired("path")
 	c.Flags().StringVarP(&r.ProjectRootPath, "root_path", "r", "", "project root path")
+	c.Flags().DurationVar(&r.Interval, "interval", 10*time.Second, "interval of checking deployments")
+	c.Flags().DurationVar(&r.Timeout, "timeout", 10*time.Minute, "timeout of watching a deployment unless watchTimeout of the service is set in config, or the tasks of a cron job from its next run")
+	c.Flags().Int32Var(&r.LogLines, "log-lines", 20, "number of log lines of failed containers to show")
+	c.Flags().BoolVar(&r.UI, "ui", false, "show a live table of the services (lines are printed if stdout is not a terminal)")
+	c.Flags().StringVar(&r.CronJob, "cron", "", "cron job name to watch the tasks of, instead of the services")
+	c.Flags().BoolVar(&r.Next, "next", false, "wait for the next run of the cron job (with --cron)")
+	c.Flags().StringVar(&r.DeployReport, "deploy-report", "", "JSON report file of deploy, the services are rolled back by bake to the task definitions before it")
 	c.Flags().BoolVarP(&ftr.Debug, "debug", "d", false, "debug option")
 }
 
@@ -46,6 +70,13 @@ type WatchRunner struct {
 	TaskName        string
 	TargetTaskPath  string
 	ProjectRootPath string
+	Interval        time.Duration
+	Timeout         time.Duration
+	LogLines        int32
+	UI              bool
+	CronJob         string
+	Next            bool
+	DeployReport    string
 }
 
 func (r *WatchRunner) preRunE(c *cobra.Command, args []string) error {
@@ -67,48 +98,269 @@ func (r *WatchRunner) preRunE(c *cobra.Command, args []string) error {
 	if strings.Contains(r.TaskName, "/") {
 		return fmt.Errorf(`invalid task name (contains "/")`)
 	}
+	if r.Interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	if r.Timeout <= 0 {
+		return errors.New("timeout must be positive")
+	}
+	if (r.CronJob != "") != r.Next {
+		return errors.New("--cron and --next must be specified together")
+	}
+	if r.CronJob != "" && r.UI {
+		return errors.New("--ui is not supported with --cron")
+	}
+	if r.CronJob != "" && r.DeployReport != "" {
+		return errors.New("--deploy-report is not supported with --cron")
+	}
 	return nil
 }
 
 func (r *WatchRunner) runE(c *cobra.Command, args []string) error {
-	deployConf := config.NewDeployConfig()
-	searchDeployConfPath := filepath.Clean(
-		strings.Join(
-			[]string{
-				r.ProjectRootPath,
-				taskPath,
-				r.TargetTaskPath,
-			},
-			"/",
-		),
-	)
-	err := deployConf.Load(searchDeployConfPath)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// A second signal terminates the process without waiting for the summary
+		stop()
+	}()
+
+	deployConf, err := loadDeployConfig(r.ProjectRootPath, r.TargetTaskPath)
+	if err != nil {
+		return err
+	}
+	if r.CronJob != "" {
+		return r.watchCronJob(ctx, deployConf)
+	}
+	previous, err := r.previousTaskDefinitions()
 	if err != nil {
 		return err
 	}
+	timeouts := map[string]map[string]time.Duration{}
+	bakes := map[string]map[string]watch.Bake{}
+	for _, s := range deployConf.GetServiceTaskConfigs(r.TaskName) {
+		if s.WatchTimeout > 0 {
+			if timeouts[s.Cluster] == nil {
+				timeouts[s.Cluster] = map[string]time.Duration{}
+			}
+			timeouts[s.Cluster][s.Service] = s.WatchTimeout
+		}
+		if s.Bake.Duration > 0 {
+			if s.Bake.Rollback && r.DeployReport != "" && previous[s.Cluster][s.Service] == "" {
+				return fmt.Errorf("service %s of cluster %s is not found in deploy report %s, it can not be rolled back", s.Service, s.Cluster, r.DeployReport)
+			}
+			if bakes[s.Cluster] == nil {
+				bakes[s.Cluster] = map[string]watch.Bake{}
+			}
+			bakes[s.Cluster][s.Service] = watch.Bake{
+				Duration: s.Bake.Duration,
+				Alarms:   s.Bake.Alarms,
+				Rollback: s.Bake.Rollback,
+				// Without the report, the task definition which is running when the watch is started is rolled back to
+				PreviousTaskDefinition: previous[s.Cluster][s.Service],
+			}
+		}
+	}
+
+	// Clusters are watched concurrently, and the limiter is shared so that they are not throttled together
+	limiter := ratelimit.New(ratelimit.DefaultRate)
+	results := make(chan watch.Result)
+	var wg sync.WaitGroup
 	servicesMap := deployConf.GetServicesMapGroupByCluster(r.TaskName)
 	for cluster, services := range servicesMap {
-		r.Watch(cluster, services)
+		w := watch.NewWatch(cluster, services, r.Interval, r.Timeout, watch.WithLimiter(limiter))
+		w.Timeouts = timeouts[cluster]
+		w.Bakes = bakes[cluster]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			go w.Start(ctx)
+			for result := range w.Results {
+				results <- result
+			}
+		}()
 	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
+	counts := map[int]int{}
+	if r.UI && isTerminal(os.Stdout) {
+		// The results of the services are printed with their stopped tasks after the table
+		for _, result := range newWatchDashboard(os.Stdout, servicesMap).run(results) {
+			r.printResult(ctx, result)
+			counts[result.Status]++
+		}
+	} else {
+		for result := range results {
+			r.printResult(ctx, result)
+			if result.Status != watch.Progress {
+				counts[result.Status]++
+			}
+		}
+	}
+	printWatchSummary(counts)
+	if ctx.Err() != nil {
+		// 130 is the exit code of the process which is interrupted
+		return &ExitError{
+			Code:    130,
+			Message: "watch is canceled",
+		}
+	}
+	var failed int
+	for status, count := range counts {
+		if status != watch.Deployed {
+			failed += count
+		}
+	}
+	if failed != 0 {
+		return &ExitError{
+			Code:    1,
+			Message: fmt.Sprintf("%d services are not deployed", failed),
+		}
+	}
 	return nil
 }
 
-func (r *WatchRunner) Watch(cluster string, services []string) {
-	interval := 10 * time.Second
-	timeout := 10 * time.Minute
-	w := watch.NewWatch(cluster, services, interval, timeout)
-	go w.Start()
-	for result := range w.Results {
-		switch result.Status {
-		case watch.Deployed:
-			fmt.Printf("Deployed [cluster: %s, service: %s]\n", result.Cluster, result.Service)
-		case watch.DeployFailed:
-			fmt.Printf("Failed to deploy [cluster: %s, service: %s]: %s\n", result.Cluster, result.Service, result.Error.Error())
-		case watch.Error:
-			fmt.Printf("Error [cluster: %s, service: %s]: %s\n", result.Cluster, result.Service, result.Error.Error())
-		case watch.Timeout:
-			fmt.Printf("Timeout [cluster: %s, service: %s]\n", result.Cluster, result.Service)
+// previousTaskDefinitions returns the task definitions of the services before the deploy by cluster and service name,
+// which are the current ones in the report of the deploy.
+func (r *WatchRunner) previousTaskDefinitions() (map[string]map[string]string, error) {
+	previous := map[string]map[string]string{}
+	if r.DeployReport == "" {
+		return previous, nil
+	}
+	rep, err := report.ReadFile(r.DeployReport)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range rep.Targets {
+		if t.Kind != report.KindService || t.CurrentTaskDefinitionArn == "" {
+			continue
 		}
+		if previous[t.Cluster] == nil {
+			previous[t.Cluster] = map[string]string{}
+		}
+		previous[t.Cluster][t.Name] = t.CurrentTaskDefinitionArn
+	}
+	return previous, nil
+}
+
+func (r *WatchRunner) printResult(ctx context.Context, result watch.Result) {
+	switch result.Status {
+	case watch.Progress:
+		fmt.Printf("%s [cluster: %s, service: %s] %s\n", au.Gray(12, formatStatusTime(&result.Time)), result.Cluster, result.Service, result.Message)
+		return
+	case watch.Deployed:
+		fmt.Printf("Deployed [cluster: %s, service: %s]\n", result.Cluster, result.Service)
+	case watch.DeployFailed:
+		fmt.Printf("Failed to deploy [cluster: %s, service: %s]: %s\n", result.Cluster, result.Service, result.Error.Error())
+	case watch.RolledBack:
+		fmt.Printf("Rolled back [cluster: %s, service: %s]: %s\n", result.Cluster, result.Service, result.Error.Error())
+	case watch.Superseded:
+		fmt.Printf("Superseded [cluster: %s, service: %s]: %s\n", result.Cluster, result.Service, result.Error.Error())
+	case watch.Error:
+		fmt.Printf("Error [cluster: %s, service: %s]: %s\n", result.Cluster, result.Service, result.Error.Error())
+	case watch.Timeout:
+		fmt.Printf("Timeout [cluster: %s, service: %s]: rolloutState: %s, running: %d/%d\n", result.Cluster, result.Service, result.RolloutState, result.RunningCount, result.DesiredCount)
+	case watch.Canceled:
+		fmt.Printf("Canceled [cluster: %s, service: %s]: rolloutState: %s, running: %d/%d\n", result.Cluster, result.Service, result.RolloutState, result.RunningCount, result.DesiredCount)
+	}
+	r.printStoppedTasks(ctx, result.StoppedTasks)
+}
+
+var watchStatusNames = map[int]string{
+	watch.Deployed:     "deployed",
+	watch.DeployFailed: "failed",
+	watch.RolledBack:   "rolled back",
+	watch.Superseded:   "superseded",
+	watch.Error:        "error",
+	watch.Timeout:      "timeout",
+	watch.Canceled:     "canceled",
+	// Statuses of the tasks of a cron job
+	watch.TaskSucceeded: "succeeded",
+	watch.TaskFailed:    "failed",
+}
+
+// printWatchSummary prints the number of services by status.
+func printWatchSummary(counts map[int]int) {
+	statuses := make([]int, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	summary := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		summary = append(summary, fmt.Sprintf("%s: %d", watchStatusNames[status], counts[status]))
+	}
+	fmt.Printf("Summary [%s]\n", strings.Join(summary, ", "))
+}
+
+// printStoppedTasks prints why the tasks are stopped and the exit codes of the essential containers, with the last logs of the failed ones.
+func (r *WatchRunner) printStoppedTasks(ctx context.Context, tasks []watch.StoppedTask) {
+	if len(tasks) == 0 {
+		return
+	}
+	cfg, cfgErr := awsconfig.LoadDefaultConfig(ctx)
+	if cfgErr != nil {
+		logrus.Warnf("failed to load aws config, logs are not shown: %s", cfgErr)
+	}
+	ecsSvc := ecs.NewFromConfig(cfg)
+	for _, t := range tasks {
+		fmt.Printf("  Stopped task [task: %s, stopped: %s]: %s: %s\n", awslogs.TaskID(t.TaskArn), formatStatusTime(t.StoppedAt), t.StopCode, t.StoppedReason)
+		var failed []watch.StoppedContainer
+		for _, c := range t.Containers {
+			if !c.Essential {
+				// Non-essential containers do not decide the result of the task
+				continue
+			}
+			exitCode := "-"
+			if c.ExitCode != nil {
+				exitCode = fmt.Sprint(*c.ExitCode)
+			}
+			fmt.Printf("    Container %s: exit code: %s", c.Name, exitCode)
+			if c.Reason != "" {
+				fmt.Printf(", reason: %s", c.Reason)
+			}
+			fmt.Println()
+			if c.Failed() {
+				failed = append(failed, c)
+			}
+		}
+		if cfgErr != nil || len(failed) == 0 || r.LogLines <= 0 {
+			continue
+		}
+		if err := r.printLastLogs(ctx, cfg, ecsSvc, t, failed); err != nil {
+			logrus.Warnf("failed to get logs of task %s: %s", awslogs.TaskID(t.TaskArn), err)
+		}
+	}
+}
+
+func (r *WatchRunner) printLastLogs(ctx context.Context, cfg aws.Config, ecsSvc *ecs.Client, t watch.StoppedTask, containers []watch.StoppedContainer) error {
+	tdRes, err := ecsSvc.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: &t.TaskDefinitionArn,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe task definition: %w", err)
+	}
+	streams := map[string]awslogs.Stream{}
+	for _, s := range awslogs.TaskStreams(tdRes.TaskDefinition.ContainerDefinitions, t.TaskArn) {
+		streams[s.Container] = s
 	}
+	for _, c := range containers {
+		s, ok := streams[c.Name]
+		if !ok {
+			fmt.Printf("    Logs of %s: not found (awslogs with awslogs-stream-prefix is required)\n", c.Name)
+			continue
+		}
+		events, err := awslogs.LastEvents(ctx, awslogs.NewClient(cfg, s.Region), s, r.LogLines)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("    Last logs of %s [group: %s, stream: %s]:\n", c.Name, s.Group, s.Name)
+		for _, e := range events {
+			fmt.Printf("      %s %s\n", e.Timestamp.Format(time.RFC3339), e.Message)
+		}
+	}
+	return nil
 }
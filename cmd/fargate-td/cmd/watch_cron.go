@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchevents"
+	cwetypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchevents/types"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	schtypes "github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+
+	"github.com/kazz187/fargate-td/internal/awslogs"
+	"github.com/kazz187/fargate-td/internal/config"
+	"github.com/kazz187/fargate-td/internal/ratelimit"
+	"github.com/kazz187/fargate-td/internal/taskdef"
+	"github.com/kazz187/fargate-td/pkg/watch"
+)
+
+// watchCronJob waits for the next run of the cron job in each cluster, and watches its tasks until they are stopped.
+func (r *WatchRunner) watchCronJob(ctx context.Context, deployConf *config.DeployConfig) error {
+	var jobs []config.CronJobTaskConfig
+	for _, job := range deployConf.GetCronJobTaskConfigs(r.TaskName) {
+		if job.CronJob == r.CronJob {
+			jobs = append(jobs, job)
+		}
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("cron job %s of task %s is not found", r.CronJob, r.TaskName)
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+	cweSvc := cloudwatchevents.NewFromConfig(cfg)
+	schSvc := scheduler.NewFromConfig(cfg)
+
+	// Resolve the launches of all clusters before watching, so that no watch is left running on an error
+	launches := make([]*watch.Launch, len(jobs))
+	now := time.Now()
+	for i, job := range jobs {
+		launch, err := cronJobLaunch(ctx, cweSvc, schSvc, job, now)
+		if err != nil {
+			return fmt.Errorf("failed to get the next run of cron job %s [cluster: %s]: %w", job.CronJob, job.Cluster, err)
+		}
+		launches[i] = launch
+	}
+
+	limiter := ratelimit.New(ratelimit.DefaultRate)
+	results := make(chan watch.TaskResult)
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		launch := launches[i]
+		fmt.Printf("Waiting for the next run [cluster: %s, cronJob: %s, at: %s]\n", job.Cluster, job.CronJob, formatStatusTime(&launch.After))
+		// The task is launched at any time within the flexible time window of the schedule
+		timeout := r.Timeout + time.Duration(job.FlexibleTimeWindow)*time.Minute
+		w := watch.NewTaskWatch(job.Cluster, nil, r.Interval, timeout, watch.WithLimiter(limiter))
+		w.Launch = launch
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			go w.Start(ctx)
+			for result := range w.Results {
+				results <- result
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	counts := map[int]int{}
+	for result := range results {
+		r.printTaskResult(ctx, result)
+		if result.Status != watch.Progress {
+			counts[result.Status]++
+		}
+	}
+	printWatchSummary(counts)
+	if ctx.Err() != nil {
+		return &ExitError{
+			Code:    130,
+			Message: "watch is canceled",
+		}
+	}
+	if counts[watch.TaskFailed]+counts[watch.Error]+counts[watch.Timeout] != 0 {
+		return &ExitError{
+			Code:    1,
+			Message: fmt.Sprintf("the next run of cron job %s is not succeeded", r.CronJob),
+		}
+	}
+	return nil
+}
+
+func (r *WatchRunner) printTaskResult(ctx context.Context, result watch.TaskResult) {
+	task := "-"
+	if result.TaskArn != "" {
+		task = awslogs.TaskID(result.TaskArn)
+	}
+	label := fmt.Sprintf("[cluster: %s, cronJob: %s, task: %s]", result.Cluster, r.CronJob, task)
+	switch result.Status {
+	case watch.Progress:
+		fmt.Printf("%s %s %s\n", au.Gray(12, formatStatusTime(&result.Time)), label, result.Message)
+	case watch.TaskSucceeded:
+		fmt.Printf("Succeeded %s\n", label)
+	case watch.TaskFailed:
+		fmt.Printf("Failed %s: %s\n", label, result.Error.Error())
+	case watch.Error:
+		fmt.Printf("Error %s: %s\n", label, result.Error.Error())
+	case watch.Timeout:
+		fmt.Printf("Timeout %s: %s\n", label, result.Error.Error())
+	case watch.Canceled:
+		fmt.Printf("Canceled %s: lastStatus: %s\n", label, valueOrDash(result.LastStatus))
+	}
+	if result.Stopped != nil {
+		// Exit codes of the essential containers, with the last logs of the failed ones
+		r.printStoppedTasks(ctx, []watch.StoppedTask{*result.Stopped})
+	}
+}
+
+// cronJobLaunch returns the launch of the next run of the cron job, by the task definition and the group of its target.
+func cronJobLaunch(ctx context.Context, cweSvc *cloudwatchevents.Client, schSvc *scheduler.Client, job config.CronJobTaskConfig, now time.Time) (*watch.Launch, error) {
+	target, err := getCronJobTaskTarget(ctx, cweSvc, schSvc, job)
+	if err != nil {
+		return nil, err
+	}
+	if !target.Enabled {
+		return nil, fmt.Errorf("cron job %s is disabled", job.CronJob)
+	}
+	runTimes, err := nextRunTimes(job, now, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(runTimes) == 0 {
+		return nil, fmt.Errorf("cron job %s has no upcoming run", job.CronJob)
+	}
+	return &watch.Launch{
+		Family: target.Family(),
+		Group:  target.Group,
+		After:  runTimes[0],
+		Count:  int(max(job.TaskCount, 1)),
+	}, nil
+}
+
+// cronJobTaskTarget is the ECS task target of the rule or the schedule of a cron job.
+type cronJobTaskTarget struct {
+	TaskDefinitionArn string
+	// Group is the task group of the launched tasks, which is "family:" + Family if it is empty
+	Group   string
+	Enabled bool
+}
+
+// Family returns the task definition family of the target.
+func (t cronJobTaskTarget) Family() string {
+	family, _, _ := strings.Cut(taskdef.Revision(t.TaskDefinitionArn), ":")
+	return family
+}
+
+// getCronJobTaskTarget returns the ECS task target of the rule or the schedule of the cron job.
+func getCronJobTaskTarget(ctx context.Context, cweSvc *cloudwatchevents.Client, schSvc *scheduler.Client, job config.CronJobTaskConfig) (cronJobTaskTarget, error) {
+	var target cronJobTaskTarget
+	if job.UsesScheduler() {
+		schedule, err := getSchedule(ctx, schSvc, job)
+		if err != nil {
+			return target, fmt.Errorf("failed to get schedule: %w", err)
+		}
+		if schedule == nil {
+			return target, fmt.Errorf("schedule %s is not found", job.CronJob)
+		}
+		target.Enabled = schedule.State != schtypes.ScheduleStateDisabled
+		if schedule.Target != nil && schedule.Target.EcsParameters != nil {
+			target.TaskDefinitionArn = aws.ToString(schedule.Target.EcsParameters.TaskDefinitionArn)
+			target.Group = aws.ToString(schedule.Target.EcsParameters.Group)
+		}
+	} else {
+		rule, err := cweSvc.DescribeRule(ctx, &cloudwatchevents.DescribeRuleInput{
+			Name: &job.CronJob,
+		})
+		if err != nil {
+			return target, fmt.Errorf("failed to get rule: %w", err)
+		}
+		target.Enabled = rule.State != cwetypes.RuleStateDisabled
+		targets, err := cweSvc.ListTargetsByRule(ctx, &cloudwatchevents.ListTargetsByRuleInput{
+			Rule: rule.Name,
+		})
+		if err != nil {
+			return target, fmt.Errorf("failed to get targets: %w", err)
+		}
+		for _, t := range targets.Targets {
+			if t.EcsParameters != nil {
+				target.TaskDefinitionArn = aws.ToString(t.EcsParameters.TaskDefinitionArn)
+				target.Group = aws.ToString(t.EcsParameters.Group)
+				break
+			}
+		}
+	}
+	if target.TaskDefinitionArn == "" {
+		return target, fmt.Errorf("ECS task target of %s is not found", job.CronJob)
+	}
+	return target, nil
+}
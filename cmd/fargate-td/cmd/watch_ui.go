@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+
+	"github.com/kazz187/fargate-td/internal/taskdef"
+	"github.com/kazz187/fargate-td/pkg/watch"
+)
+
+// watchDashboard is the live table of the services of watch --ui, which is redrawn in place.
+type watchDashboard struct {
+	out   *os.File
+	start time.Time
+	rows  map[string]*dashboardRow
+	keys  []string
+	// lines is the number of the lines which are drawn last time
+	lines int
+}
+
+type dashboardRow struct {
+	cluster, service string
+	revision         string
+	desired          int32
+	running          int32
+	pending          int32
+	rolloutState     string
+	bakeUntil        time.Time
+	event            string
+	// status is the status of the finished deployment, or watch.Deploying
+	status     int
+	finishedAt time.Time
+}
+
+func newWatchDashboard(out *os.File, servicesMap map[string][]string) *watchDashboard {
+	d := &watchDashboard{
+		out:   out,
+		start: time.Now(),
+		rows:  map[string]*dashboardRow{},
+	}
+	for cluster, services := range servicesMap {
+		for _, service := range services {
+			d.row(cluster, service)
+		}
+	}
+	return d
+}
+
+func (d *watchDashboard) row(cluster, service string) *dashboardRow {
+	key := cluster + "/" + service
+	row, ok := d.rows[key]
+	if !ok {
+		row = &dashboardRow{cluster: cluster, service: service, status: watch.Deploying}
+		d.rows[key] = row
+		d.keys = append(d.keys, key)
+		sort.Strings(d.keys)
+	}
+	return row
+}
+
+// run redraws the table until the results are closed, and returns the results which are not progress.
+// Logs are held while the table is drawn, and written after it.
+func (d *watchDashboard) run(results <-chan watch.Result) []watch.Result {
+	logs := &bytes.Buffer{}
+	logrus.SetOutput(logs)
+	defer func() {
+		logrus.SetOutput(os.Stderr)
+		_, _ = io.Copy(os.Stderr, logs)
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var finished []watch.Result
+	d.render()
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				d.render()
+				return finished
+			}
+			if result.Status != watch.Progress {
+				finished = append(finished, result)
+			}
+			d.update(result)
+		case <-ticker.C:
+			d.render()
+		}
+	}
+}
+
+func (d *watchDashboard) update(result watch.Result) {
+	if result.Cluster == "" {
+		// The error is not of a service, such as the failure to load the aws config
+		return
+	}
+	row := d.row(result.Cluster, result.Service)
+	// Results without the task definition have no counts of the deployment, such as service events
+	if result.TaskDefinition != "" {
+		row.revision = taskdef.Revision(result.TaskDefinition)
+		row.desired, row.running, row.pending = result.DesiredCount, result.RunningCount, result.PendingCount
+		row.rolloutState = string(result.RolloutState)
+	}
+	if !result.BakeUntil.IsZero() {
+		row.bakeUntil = result.BakeUntil
+	}
+	switch {
+	case result.Status == watch.Progress:
+		if result.ProgressKind != watch.ProgressCounts {
+			row.event = result.Message
+		}
+	case result.Status != watch.Deploying:
+		row.status = result.Status
+		row.finishedAt = time.Now()
+		if result.Error != nil {
+			row.event = result.Error.Error()
+		}
+	}
+}
+
+func (d *watchDashboard) render() {
+	now := time.Now()
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CLUSTER/SERVICE\tREVISION\tDESIRED\tRUNNING\tPENDING\tSTATE\tELAPSED\tLATEST EVENT")
+	for _, key := range d.keys {
+		row := d.rows[key]
+		elapsed := now.Sub(d.start)
+		if !row.finishedAt.IsZero() {
+			elapsed = row.finishedAt.Sub(d.start)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n",
+			key, valueOrDash(row.revision), row.desired, row.running, row.pending,
+			row.state(now), elapsed.Round(time.Second), row.event)
+	}
+	_ = tw.Flush()
+
+	width, _, err := term.GetSize(int(d.out.Fd()))
+	if err != nil || width <= 0 {
+		width = 120
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	out := &strings.Builder{}
+	if d.lines > 0 {
+		// Move the cursor up to the first line of the last table, and clear it
+		fmt.Fprintf(out, "\x1b[%dA\x1b[J", d.lines)
+	}
+	for i, line := range lines {
+		// Lines are truncated not to be wrapped, or the cursor does not go back to the first line
+		if r := []rune(line); len(r) >= width {
+			line = string(r[:width-1])
+		}
+		if i > 0 {
+			line = d.rows[d.keys[i-1]].colorize(line)
+		}
+		out.WriteString(line + "\n")
+	}
+	_, _ = io.WriteString(d.out, out.String())
+	d.lines = len(lines)
+}
+
+func (row *dashboardRow) state(now time.Time) string {
+	if row.status != watch.Deploying {
+		return watchStatusNames[row.status]
+	}
+	if !row.bakeUntil.IsZero() && now.Before(row.bakeUntil) {
+		return fmt.Sprintf("baking (%s left)", row.bakeUntil.Sub(now).Round(time.Second))
+	}
+	return valueOrDash(row.rolloutState)
+}
+
+func (row *dashboardRow) colorize(line string) string {
+	switch row.status {
+	case watch.Deploying:
+		return line
+	case watch.Deployed:
+		return au.Green(line).String()
+	case watch.Timeout, watch.Canceled, watch.Superseded:
+		return au.Yellow(line).String()
+	default:
+		return au.Red(line).String()
+	}
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
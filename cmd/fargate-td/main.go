@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/kazz187/fargate-td/cmd/fargate-td/cmd"
@@ -8,6 +9,10 @@ import (
 
 func main() {
 	if err := cmd.NewFargateTdCommand().Execute(); err != nil {
+		var exitErr *cmd.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,233 @@
+package awslogs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwltypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const logDriver = "awslogs"
+
+// Stream is the awslogs log stream of a container of a task.
+type Stream struct {
+	Container string
+	TaskID    string
+	Group     string
+	Name      string
+	Region    string
+}
+
+// Event is a log event of a container.
+type Event struct {
+	ID        string
+	Container string
+	TaskID    string
+	Timestamp time.Time
+	Message   string
+}
+
+// LogConfig is the awslogs configuration of a container.
+type LogConfig struct {
+	Container    string
+	Group        string
+	StreamPrefix string
+	Region       string
+}
+
+// LogConfigs returns the awslogs configurations of the containers.
+// Containers which do not use the awslogs log driver are skipped.
+func LogConfigs(containers []types.ContainerDefinition) []LogConfig {
+	var configs []LogConfig
+	for _, c := range containers {
+		if c.LogConfiguration == nil || c.LogConfiguration.LogDriver != logDriver {
+			continue
+		}
+		opts := c.LogConfiguration.Options
+		configs = append(configs, LogConfig{
+			Container:    aws.ToString(c.Name),
+			Group:        opts["awslogs-group"],
+			StreamPrefix: opts["awslogs-stream-prefix"],
+			Region:       opts["awslogs-region"],
+		})
+	}
+	return configs
+}
+
+// Stream returns the log stream of the container in the task.
+// The stream name is "prefix/container-name/task-id" as awslogs names it.
+func (lc LogConfig) Stream(taskArn string) (Stream, bool) {
+	if lc.StreamPrefix == "" {
+		// The stream name can not be determined without the prefix
+		return Stream{}, false
+	}
+	return Stream{
+		Container: lc.Container,
+		TaskID:    TaskID(taskArn),
+		Group:     lc.Group,
+		Name:      lc.StreamPrefix + "/" + lc.Container + "/" + TaskID(taskArn),
+		Region:    lc.Region,
+	}, true
+}
+
+// TaskStreams returns the log streams of the containers of the task.
+func TaskStreams(containers []types.ContainerDefinition, taskArn string) []Stream {
+	var streams []Stream
+	for _, lc := range LogConfigs(containers) {
+		if s, ok := lc.Stream(taskArn); ok {
+			streams = append(streams, s)
+		}
+	}
+	return streams
+}
+
+// TaskID returns the ID of the task from the task ARN.
+func TaskID(taskArn string) string {
+	return taskArn[strings.LastIndex(taskArn, "/")+1:]
+}
+
+// NewClient returns a client of CloudWatch Logs for the region.
+// If region is empty, the region of cfg is used.
+func NewClient(cfg aws.Config, region string) *cloudwatchlogs.Client {
+	return cloudwatchlogs.NewFromConfig(cfg, func(o *cloudwatchlogs.Options) {
+		if region != "" {
+			o.Region = region
+		}
+	})
+}
+
+// Tailer fetches new log events of a log stream.
+type Tailer struct {
+	client    *cloudwatchlogs.Client
+	stream    Stream
+	nextToken *string
+}
+
+func NewTailer(client *cloudwatchlogs.Client, stream Stream) *Tailer {
+	return &Tailer{
+		client: client,
+		stream: stream,
+	}
+}
+
+// Fetch returns the log events which are written after the last fetch.
+func (t *Tailer) Fetch(ctx context.Context) ([]Event, error) {
+	var events []Event
+	for {
+		out, err := t.client.GetLogEvents(ctx, &cloudwatchlogs.GetLogEventsInput{
+			LogGroupName:  &t.stream.Group,
+			LogStreamName: &t.stream.Name,
+			NextToken:     t.nextToken,
+			StartFromHead: aws.Bool(true),
+		})
+		if err != nil {
+			var notFound *cwltypes.ResourceNotFoundException
+			if errors.As(err, &notFound) {
+				// The log stream is not created until the container starts
+				return events, nil
+			}
+			return events, err
+		}
+		for _, e := range out.Events {
+			events = append(events, Event{
+				Container: t.stream.Container,
+				TaskID:    t.stream.TaskID,
+				Timestamp: time.UnixMilli(aws.ToInt64(e.Timestamp)),
+				Message:   aws.ToString(e.Message),
+			})
+		}
+		if t.nextToken != nil && aws.ToString(out.NextForwardToken) == *t.nextToken {
+			return events, nil
+		}
+		t.nextToken = out.NextForwardToken
+		if len(out.Events) == 0 {
+			return events, nil
+		}
+	}
+}
+
+// LastEvents returns the last log events of the stream, up to limit.
+func LastEvents(ctx context.Context, client *cloudwatchlogs.Client, stream Stream, limit int32) ([]Event, error) {
+	out, err := client.GetLogEvents(ctx, &cloudwatchlogs.GetLogEventsInput{
+		LogGroupName:  &stream.Group,
+		LogStreamName: &stream.Name,
+		Limit:         &limit,
+		StartFromHead: aws.Bool(false),
+	})
+	if err != nil {
+		var notFound *cwltypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			// The log stream is not created if the container did not start
+			return nil, nil
+		}
+		return nil, err
+	}
+	events := make([]Event, 0, len(out.Events))
+	for _, e := range out.Events {
+		events = append(events, Event{
+			Container: stream.Container,
+			TaskID:    stream.TaskID,
+			Timestamp: time.UnixMilli(aws.ToInt64(e.Timestamp)),
+			Message:   aws.ToString(e.Message),
+		})
+	}
+	return events, nil
+}
+
+// maxFilterStreams is the maximum number of log stream names of FilterLogEvents.
+const maxFilterStreams = 100
+
+// FilterEvents returns the log events of the streams since start, sorted by timestamp.
+// The streams must be in the same log group.
+func FilterEvents(ctx context.Context, client *cloudwatchlogs.Client, streams []Stream, start time.Time) ([]Event, error) {
+	if len(streams) == 0 {
+		return nil, nil
+	}
+	streamMap := map[string]Stream{}
+	var names []string
+	for _, s := range streams {
+		streamMap[s.Name] = s
+		names = append(names, s.Name)
+	}
+	var events []Event
+	for i := 0; i < len(names); i += maxFilterStreams {
+		end := min(i+maxFilterStreams, len(names))
+		in := &cloudwatchlogs.FilterLogEventsInput{
+			LogGroupName:   &streams[0].Group,
+			LogStreamNames: names[i:end],
+			StartTime:      aws.Int64(start.UnixMilli()),
+		}
+		p := cloudwatchlogs.NewFilterLogEventsPaginator(client, in)
+		for p.HasMorePages() {
+			out, err := p.NextPage(ctx)
+			if err != nil {
+				var notFound *cwltypes.ResourceNotFoundException
+				if errors.As(err, &notFound) {
+					// Log streams of starting tasks are not created yet
+					break
+				}
+				return nil, err
+			}
+			for _, e := range out.Events {
+				s := streamMap[aws.ToString(e.LogStreamName)]
+				events = append(events, Event{
+					ID:        aws.ToString(e.EventId),
+					Container: s.Container,
+					TaskID:    s.TaskID,
+					Timestamp: time.UnixMilli(aws.ToInt64(e.Timestamp)),
+					Message:   aws.ToString(e.Message),
+				})
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events, nil
+}
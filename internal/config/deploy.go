@@ -1,61 +1,226 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/kazz187/fargate-td/internal/cron"
 	"github.com/kazz187/fargate-td/internal/util"
 )
 
+const (
+	SchedulerCloudWatchEvents     = "cloudwatch-events"
+	SchedulerEventBridgeScheduler = "eventbridge-scheduler"
+)
+
+const (
+	CronJobStateEnabled  = "enabled"
+	CronJobStateDisabled = "disabled"
+)
+
+const (
+	LockBackendDynamoDB = "dynamodb"
+	LockBackendECSTag   = "ecs-tag"
+	LockBackendFile     = "file"
+)
+
+// DefaultLockTTL is the TTL of deploy locks, if it is not set in config.
+const DefaultLockTTL = 30 * time.Minute
+
+// ErrConfigNotFound is returned when the deploy config file is not found.
+var ErrConfigNotFound = errors.New("deploy config file is not found")
+
 type DeployConfig struct {
 	serviceTaskConfig map[string][]ServiceTaskConfig
 	cronJobTaskConfig map[string][]CronJobTaskConfig
+	runTaskConfig     map[string][]RunTaskConfig
+	redaction         RedactionConfig
+	lock              LockConfig
+	protectedClusters map[string]bool
 }
 
 type ServiceTaskConfig struct {
 	Cluster string
 	Service string
+	// WatchTimeout is the timeout of watching the deployment of the service (default: the timeout of watch)
+	WatchTimeout time.Duration
+	Bake         BakeConfig
 }
 
 type CronJobTaskConfig struct {
-	Cluster string
-	CronJob string
-	Cron    string
+	Cluster              string
+	CronJob              string
+	Cron                 string
+	RoleArn              string
+	LaunchType           string
+	PlatformVersion      string
+	NetworkConfiguration *NetworkConfiguration
+	TaskCount            int32
+	Group                string
+	Tags                 map[string]string
+	Cpu                  string
+	Memory               string
+	ContainerOverrides   []ContainerOverride
+	Scheduler            string
+	ScheduleGroup        string
+	Timezone             string
+	StartDate            *time.Time
+	EndDate              *time.Time
+	FlexibleTimeWindow   int32
+	DeadLetterQueue      string
+	RetryPolicy          *RetryPolicy
+	// State is empty if the state of the cron job is not managed by config
+	State string
+}
+
+// UsesScheduler reports whether the cron job is managed as an EventBridge Scheduler schedule.
+func (c CronJobTaskConfig) UsesScheduler() bool {
+	return c.Scheduler == SchedulerEventBridgeScheduler
+}
+
+type RetryPolicy struct {
+	MaximumRetryAttempts     *int32 `yaml:"maximumRetryAttempts"`
+	MaximumEventAgeInSeconds *int32 `yaml:"maximumEventAgeInSeconds"`
+}
+
+// RunTaskConfig is the config to run a one-off task.
+// The network configuration is copied from Service if it is set.
+type RunTaskConfig struct {
+	Cluster              string
+	Service              string
+	LaunchType           string
+	PlatformVersion      string
+	NetworkConfiguration *NetworkConfiguration
+}
+
+// RedactionConfig is the config to mask sensitive values in the output.
+type RedactionConfig struct {
+	// Patterns are the patterns of the names of environment variables whose values are masked
+	Patterns []string `yaml:"patterns"`
+	// Variables are the names of the variables whose values are masked
+	Variables []string `yaml:"variables"`
+}
+
+// LockConfig is the config of the lock to prevent concurrent deploys of the same task.
+// Deploys are not locked if Backend is empty.
+type LockConfig struct {
+	Backend string        `yaml:"backend"`
+	TTL     time.Duration `yaml:"ttl"`
+	// Table is the DynamoDB table of the dynamodb backend
+	Table string `yaml:"table"`
+	// Cluster and Service are the ECS service to be tagged by the ecs-tag backend (default: the first service of the task)
+	Cluster string `yaml:"cluster"`
+	Service string `yaml:"service"`
+	// Dir is the directory of the file backend, relative to the project root
+	Dir string `yaml:"dir"`
+}
+
+// BakeConfig is the config of the bake time of a service after it is deployed.
+// During the bake time, the deployment is failed if any of Alarms enters ALARM.
+type BakeConfig struct {
+	Duration time.Duration `yaml:"duration"`
+	// Alarms are the names of the CloudWatch alarms to be monitored
+	Alarms []string `yaml:"alarms"`
+	// Rollback rolls the service back to the previous task definition if an alarm enters ALARM
+	Rollback bool `yaml:"rollback"`
+}
+
+type NetworkConfiguration struct {
+	Subnets        []string `yaml:"subnets"`
+	SecurityGroups []string `yaml:"securityGroups"`
+	AssignPublicIp string   `yaml:"assignPublicIp"`
+}
+
+type ContainerOverride struct {
+	Name        string                `yaml:"name"`
+	Command     []string              `yaml:"command"`
+	Environment []EnvironmentVariable `yaml:"environment"`
+	Cpu         int32                 `yaml:"cpu"`
+	Memory      int32                 `yaml:"memory"`
+}
+
+type EnvironmentVariable struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
 }
 
 type config struct {
-	Clusters []cluster `yaml:"clusters"`
+	// AllowStandardCron enables to write cron of cron jobs in standard five-field cron format
+	AllowStandardCron bool            `yaml:"allowStandardCron"`
+	Redaction         RedactionConfig `yaml:"redaction"`
+	Lock              LockConfig      `yaml:"lock"`
+	Clusters          []cluster       `yaml:"clusters"`
 }
 
 type cluster struct {
-	Name     string    `yaml:"name"`
-	Services []service `yaml:"services"`
-	CronJobs []cronJob `yaml:"cronJobs"`
+	Name string `yaml:"name"`
+	// Protected requires the confirmation with the cluster name to deploy
+	Protected bool      `yaml:"protected"`
+	Services  []service `yaml:"services"`
+	CronJobs  []cronJob `yaml:"cronJobs"`
+	RunTasks  []runTask `yaml:"runTasks"`
 }
 
 type service struct {
-	Name string `yaml:"name"`
-	Task string `yaml:"task"`
+	Name         string        `yaml:"name"`
+	Task         string        `yaml:"task"`
+	WatchTimeout time.Duration `yaml:"watchTimeout"`
+	Bake         BakeConfig    `yaml:"bake"`
+}
+
+type runTask struct {
+	Task                 string                `yaml:"task"`
+	Service              string                `yaml:"service"`
+	LaunchType           string                `yaml:"launchType"`
+	PlatformVersion      string                `yaml:"platformVersion"`
+	NetworkConfiguration *NetworkConfiguration `yaml:"networkConfiguration"`
 }
 
 type cronJob struct {
-	Name string `yaml:"name"`
-	Task string `yaml:"task"`
-	Cron string `yaml:"cron"`
+	Name                 string                `yaml:"name"`
+	Task                 string                `yaml:"task"`
+	Cron                 string                `yaml:"cron"`
+	RoleArn              string                `yaml:"roleArn"`
+	LaunchType           string                `yaml:"launchType"`
+	PlatformVersion      string                `yaml:"platformVersion"`
+	NetworkConfiguration *NetworkConfiguration `yaml:"networkConfiguration"`
+	TaskCount            int32                 `yaml:"taskCount"`
+	Group                string                `yaml:"group"`
+	Tags                 map[string]string     `yaml:"tags"`
+	Cpu                  string                `yaml:"cpu"`
+	Memory               string                `yaml:"memory"`
+	ContainerOverrides   []ContainerOverride   `yaml:"containerOverrides"`
+	Scheduler            string                `yaml:"scheduler"`
+	ScheduleGroup        string                `yaml:"scheduleGroup"`
+	Timezone             string                `yaml:"timezone"`
+	StartDate            *time.Time            `yaml:"startDate"`
+	EndDate              *time.Time            `yaml:"endDate"`
+	FlexibleTimeWindow   int32                 `yaml:"flexibleTimeWindow"`
+	DeadLetterQueue      string                `yaml:"deadLetterQueue"`
+	RetryPolicy          *RetryPolicy          `yaml:"retryPolicy"`
+	State                string                `yaml:"state"`
 }
 
 func NewDeployConfig() *DeployConfig {
 	return &DeployConfig{
 		serviceTaskConfig: map[string][]ServiceTaskConfig{},
 		cronJobTaskConfig: map[string][]CronJobTaskConfig{},
+		runTaskConfig:     map[string][]RunTaskConfig{},
+		protectedClusters: map[string]bool{},
 	}
 }
 
 func (dc *DeployConfig) Load(searchPath string) error {
 	configFile, err := searchConfigFile(searchPath)
+	if err != nil {
+		return err
+	}
 	f, err := os.ReadFile(configFile)
 	if err != nil {
 		return fmt.Errorf("failed to read deploy config file %s: %w", configFile, err)
@@ -65,30 +230,106 @@ func (dc *DeployConfig) Load(searchPath string) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse deploy config file %s: %w", configFile, err)
 	}
+	dc.redaction = conf.Redaction
+	switch conf.Lock.Backend {
+	case "", LockBackendECSTag:
+	case LockBackendDynamoDB:
+		if conf.Lock.Table == "" {
+			return fmt.Errorf("table of lock is required for %s backend in %s", LockBackendDynamoDB, configFile)
+		}
+	case LockBackendFile:
+		if conf.Lock.Dir == "" {
+			conf.Lock.Dir = ".fargate-td/lock"
+		}
+	default:
+		return fmt.Errorf("invalid lock backend %s in %s", conf.Lock.Backend, configFile)
+	}
+	if conf.Lock.TTL == 0 {
+		conf.Lock.TTL = DefaultLockTTL
+	}
+	dc.lock = conf.Lock
 	for _, c := range conf.Clusters {
+		if c.Protected {
+			dc.protectedClusters[c.Name] = true
+		}
 		for _, s := range c.Services {
+			if len(s.Bake.Alarms) != 0 && s.Bake.Duration <= 0 {
+				return fmt.Errorf("duration of bake is required for alarms of service %s in %s", s.Name, configFile)
+			}
 			taskConfigList, ok := dc.serviceTaskConfig[s.Task]
 			if !ok {
 				taskConfigList = []ServiceTaskConfig{}
 			}
 			taskConfigList = append(taskConfigList, ServiceTaskConfig{
-				Cluster: c.Name,
-				Service: s.Name,
+				Cluster:      c.Name,
+				Service:      s.Name,
+				WatchTimeout: s.WatchTimeout,
+				Bake:         s.Bake,
 			})
 			dc.serviceTaskConfig[s.Task] = taskConfigList
 		}
 		for _, cj := range c.CronJobs {
+			switch cj.Scheduler {
+			case "":
+				cj.Scheduler = SchedulerCloudWatchEvents
+			case SchedulerCloudWatchEvents, SchedulerEventBridgeScheduler:
+			default:
+				return fmt.Errorf("invalid scheduler %s of cron job %s in %s", cj.Scheduler, cj.Name, configFile)
+			}
+			if cj.Scheduler == SchedulerEventBridgeScheduler && cj.ScheduleGroup == "" {
+				cj.ScheduleGroup = "default"
+			}
+			switch cj.State {
+			case "", CronJobStateEnabled, CronJobStateDisabled:
+			default:
+				return fmt.Errorf("invalid state %s of cron job %s in %s", cj.State, cj.Name, configFile)
+			}
+			cj.Cron, err = cron.Normalize(cj.Cron, conf.AllowStandardCron)
+			if err != nil {
+				return fmt.Errorf("invalid cron of cron job %s in %s: %w", cj.Name, configFile, err)
+			}
+			if cron.IsOneTime(cj.Cron) && cj.Scheduler != SchedulerEventBridgeScheduler {
+				return fmt.Errorf("invalid cron of cron job %s in %s: at(...) is supported only by %s", cj.Name, configFile, SchedulerEventBridgeScheduler)
+			}
 			taskConfigList, ok := dc.cronJobTaskConfig[cj.Task]
 			if !ok {
 				taskConfigList = []CronJobTaskConfig{}
 			}
 			taskConfigList = append(taskConfigList, CronJobTaskConfig{
-				Cluster: c.Name,
-				CronJob: cj.Name,
-				Cron:    cj.Cron,
+				Cluster:              c.Name,
+				CronJob:              cj.Name,
+				Cron:                 cj.Cron,
+				RoleArn:              cj.RoleArn,
+				LaunchType:           cj.LaunchType,
+				PlatformVersion:      cj.PlatformVersion,
+				NetworkConfiguration: cj.NetworkConfiguration,
+				TaskCount:            cj.TaskCount,
+				Group:                cj.Group,
+				Tags:                 cj.Tags,
+				Cpu:                  cj.Cpu,
+				Memory:               cj.Memory,
+				ContainerOverrides:   cj.ContainerOverrides,
+				Scheduler:            cj.Scheduler,
+				ScheduleGroup:        cj.ScheduleGroup,
+				Timezone:             cj.Timezone,
+				StartDate:            cj.StartDate,
+				EndDate:              cj.EndDate,
+				FlexibleTimeWindow:   cj.FlexibleTimeWindow,
+				DeadLetterQueue:      cj.DeadLetterQueue,
+				RetryPolicy:          cj.RetryPolicy,
+				State:                cj.State,
 			})
 			dc.cronJobTaskConfig[cj.Task] = taskConfigList
 		}
+		for _, rt := range c.RunTasks {
+			dc.runTaskConfig[rt.Task] = append(dc.runTaskConfig[rt.Task], RunTaskConfig{
+				Cluster:              c.Name,
+				Service:              rt.Service,
+				LaunchType:           rt.LaunchType,
+				PlatformVersion:      rt.PlatformVersion,
+				NetworkConfiguration: rt.NetworkConfiguration,
+			})
+		}
 	}
 	return nil
 }
@@ -104,7 +345,37 @@ func searchConfigFile(searchPath string) (string, error) {
 			return path, nil
 		}
 	}
-	return "", fmt.Errorf("deploy config file is not found in %s", filepath.Clean(searchPath))
+	return "", fmt.Errorf("%w in %s", ErrConfigNotFound, filepath.Clean(searchPath))
+}
+
+// Redaction returns the config to mask sensitive values.
+func (dc *DeployConfig) Redaction() RedactionConfig {
+	return dc.redaction
+}
+
+// Lock returns the config of the deploy lock.
+func (dc *DeployConfig) Lock() LockConfig {
+	return dc.lock
+}
+
+// IsProtected reports whether the cluster requires the confirmation with its name to deploy.
+func (dc *DeployConfig) IsProtected(cluster string) bool {
+	return dc.protectedClusters[cluster]
+}
+
+// Tasks returns the names of the tasks which are used by services or cron jobs.
+func (dc *DeployConfig) Tasks() []string {
+	var tasks []string
+	for task := range dc.serviceTaskConfig {
+		tasks = append(tasks, task)
+	}
+	for task := range dc.cronJobTaskConfig {
+		if _, ok := dc.serviceTaskConfig[task]; !ok {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Strings(tasks)
+	return tasks
 }
 
 func (dc *DeployConfig) GetServiceTaskConfigs(task string) []ServiceTaskConfig {
@@ -123,6 +394,14 @@ func (dc *DeployConfig) GetCronJobTaskConfigs(task string) []CronJobTaskConfig {
 	return cjtc
 }
 
+func (dc *DeployConfig) GetRunTaskConfigs(task string) []RunTaskConfig {
+	rtc, ok := dc.runTaskConfig[task]
+	if !ok {
+		return []RunTaskConfig{}
+	}
+	return rtc
+}
+
 func (dc *DeployConfig) GetServicesMapGroupByCluster(task string) map[string][]string {
 	serviceTaskConfList := dc.serviceTaskConfig[task]
 	clusters := map[string][]string{}
@@ -0,0 +1,446 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	minYear = 1970
+	maxYear = 2199
+)
+
+// Schedule is a parsed schedule expression.
+type Schedule interface {
+	// Next returns the first run time after t in the location of t,
+	// or the zero time if the schedule never runs after t.
+	Next(t time.Time) time.Time
+}
+
+// Parse parses a schedule expression of AWS (cron(...), rate(...) or at(...)).
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	switch {
+	case strings.HasPrefix(expr, "cron(") && strings.HasSuffix(expr, ")"):
+		return parseCron(expr[len("cron(") : len(expr)-1])
+	case strings.HasPrefix(expr, "rate(") && strings.HasSuffix(expr, ")"):
+		return parseRate(expr[len("rate(") : len(expr)-1])
+	case strings.HasPrefix(expr, "at(") && strings.HasSuffix(expr, ")"):
+		return parseAt(expr[len("at(") : len(expr)-1])
+	}
+	return nil, fmt.Errorf("invalid schedule expression %q: must be cron(...), rate(...) or at(...)", expr)
+}
+
+// Normalize validates the schedule expression and returns it in the AWS format.
+// If allowStandard is true, a standard five-field cron expression is converted to cron(...).
+func Normalize(expr string, allowStandard bool) (string, error) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasSuffix(expr, ")") && allowStandard {
+		converted, err := FromStandard(expr)
+		if err != nil {
+			return "", err
+		}
+		expr = converted
+	}
+	if _, err := Parse(expr); err != nil {
+		return "", err
+	}
+	return expr, nil
+}
+
+var standardMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// FromStandard converts a standard five-field cron expression to the AWS format.
+func FromStandard(expr string) (string, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := standardMacros[expr]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return "", fmt.Errorf("invalid cron expression %q: standard cron must have 5 fields", expr)
+	}
+	dom, dow := fields[2], fields[4]
+	switch {
+	case dow == "*":
+		dow = "?"
+	case dom == "*":
+		dom = "?"
+		// Standard cron counts the day of week from 0 (Sunday), AWS counts from 1 (Sunday)
+		days, err := parseField(dow, 0, 7, dayNames0)
+		if err != nil {
+			return "", fmt.Errorf("invalid cron expression %q: day of week: %w", expr, err)
+		}
+		dow = formatDaysOfWeek(days)
+	default:
+		return "", fmt.Errorf("invalid cron expression %q: day of month and day of week can not be specified at the same time", expr)
+	}
+	minutes := replaceWildcardIncrement(fields[0], 0)
+	hours := replaceWildcardIncrement(fields[1], 0)
+	month := replaceWildcardIncrement(fields[3], 1)
+	dom = replaceWildcardIncrement(dom, 1)
+	return fmt.Sprintf("cron(%s %s %s %s %s *)", minutes, hours, dom, month, dow), nil
+}
+
+// replaceWildcardIncrement replaces "*/n" with "min/n", which is the increment format of AWS.
+func replaceWildcardIncrement(field string, min int) string {
+	elems := strings.Split(field, ",")
+	for i, elem := range elems {
+		if strings.HasPrefix(elem, "*/") {
+			elems[i] = strconv.Itoa(min) + strings.TrimPrefix(elem, "*")
+		}
+	}
+	return strings.Join(elems, ",")
+}
+
+func formatDaysOfWeek(days []bool) string {
+	var list []string
+	all := true
+	for d := 0; d < 7; d++ {
+		// 7 is also Sunday in standard cron
+		if days[d] || (d == 0 && days[7]) {
+			list = append(list, strconv.Itoa(d+1))
+		} else {
+			all = false
+		}
+	}
+	if all {
+		return "*"
+	}
+	return strings.Join(list, ",")
+}
+
+var monthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var dayNames = map[string]int{
+	"SUN": 1, "MON": 2, "TUE": 3, "WED": 4, "THU": 5, "FRI": 6, "SAT": 7,
+}
+
+var dayNames0 = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+type cronSchedule struct {
+	minutes, hours, months, years []bool
+	dom                           daysOfMonth
+	dow                           daysOfWeek
+}
+
+// daysOfMonth is the day-of-month field. nil days means "?".
+type daysOfMonth struct {
+	days        []bool
+	last        bool
+	lastWeekday bool
+	weekday     int
+}
+
+// daysOfWeek is the day-of-week field. nil days means "?".
+type daysOfWeek struct {
+	days []bool
+	last int
+	day  int
+	nth  int
+}
+
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("invalid cron expression %q: must have 6 fields (minutes hours day-of-month month day-of-week year)", expr)
+	}
+	s := &cronSchedule{}
+	var err error
+	if s.minutes, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: minutes: %w", expr, err)
+	}
+	if s.hours, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: hours: %w", expr, err)
+	}
+	if s.dom, err = parseDaysOfMonth(fields[2]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: day of month: %w", expr, err)
+	}
+	if s.months, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: month: %w", expr, err)
+	}
+	if s.dow, err = parseDaysOfWeek(fields[4]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: day of week: %w", expr, err)
+	}
+	if s.years, err = parseField(fields[5], minYear, maxYear, nil); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: year: %w", expr, err)
+	}
+	domUnset := fields[2] == "?"
+	dowUnset := fields[4] == "?"
+	if domUnset == dowUnset {
+		return nil, fmt.Errorf("invalid cron expression %q: either day of month or day of week must be ?", expr)
+	}
+	return s, nil
+}
+
+// parseField parses a list of values, ranges, wildcards and increments.
+func parseField(field string, min, max int, names map[string]int) ([]bool, error) {
+	set := make([]bool, max+1)
+	for _, elem := range strings.Split(field, ",") {
+		rangeStr, stepStr, hasStep := strings.Cut(elem, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid increment %q", elem)
+			}
+		}
+		var from, to int
+		switch {
+		case rangeStr == "*":
+			from, to = min, max
+		case strings.Contains(rangeStr, "-"):
+			fromStr, toStr, _ := strings.Cut(rangeStr, "-")
+			var err error
+			if from, err = parseValue(fromStr, min, max, names); err != nil {
+				return nil, err
+			}
+			if to, err = parseValue(toStr, min, max, names); err != nil {
+				return nil, err
+			}
+			if from > to {
+				return nil, fmt.Errorf("invalid range %q", rangeStr)
+			}
+		default:
+			var err error
+			if from, err = parseValue(rangeStr, min, max, names); err != nil {
+				return nil, err
+			}
+			to = from
+			if hasStep {
+				to = max
+			}
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+func parseValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("value %d is out of range (%d-%d)", v, min, max)
+	}
+	return v, nil
+}
+
+func parseDaysOfMonth(field string) (daysOfMonth, error) {
+	switch {
+	case field == "?":
+		return daysOfMonth{}, nil
+	case field == "L":
+		return daysOfMonth{last: true}, nil
+	case field == "LW":
+		return daysOfMonth{lastWeekday: true}, nil
+	case strings.HasSuffix(field, "W"):
+		day, err := parseValue(strings.TrimSuffix(field, "W"), 1, 31, nil)
+		if err != nil {
+			return daysOfMonth{}, err
+		}
+		return daysOfMonth{weekday: day}, nil
+	}
+	days, err := parseField(field, 1, 31, nil)
+	if err != nil {
+		return daysOfMonth{}, err
+	}
+	return daysOfMonth{days: days}, nil
+}
+
+func parseDaysOfWeek(field string) (daysOfWeek, error) {
+	switch {
+	case field == "?":
+		return daysOfWeek{}, nil
+	case field == "L":
+		return daysOfWeek{days: []bool{false, false, false, false, false, false, false, true}}, nil
+	case strings.HasSuffix(field, "L"):
+		day, err := parseValue(strings.TrimSuffix(field, "L"), 1, 7, dayNames)
+		if err != nil {
+			return daysOfWeek{}, err
+		}
+		return daysOfWeek{last: day}, nil
+	case strings.Contains(field, "#"):
+		dayStr, nthStr, _ := strings.Cut(field, "#")
+		day, err := parseValue(dayStr, 1, 7, dayNames)
+		if err != nil {
+			return daysOfWeek{}, err
+		}
+		nth, err := parseValue(nthStr, 1, 5, nil)
+		if err != nil {
+			return daysOfWeek{}, err
+		}
+		return daysOfWeek{day: day, nth: nth}, nil
+	}
+	days, err := parseField(field, 1, 7, dayNames)
+	if err != nil {
+		return daysOfWeek{}, err
+	}
+	return daysOfWeek{days: days}, nil
+}
+
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	// The wall clock of t is searched in UTC, which has no daylight saving time,
+	// since time.Date normalizes the time which does not exist in loc to another hour
+	w := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, time.UTC)
+	for w.Year() <= maxYear {
+		switch {
+		case w.Year() < minYear || !s.years[w.Year()]:
+			w = time.Date(w.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.months[w.Month()]:
+			w = time.Date(w.Year(), w.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.matchDay(w):
+			w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !s.hours[w.Hour()]:
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour()+1, 0, 0, 0, time.UTC)
+		case !s.minutes[w.Minute()]:
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute()+1, 0, 0, time.UTC)
+		default:
+			next := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), 0, 0, loc)
+			// As EventBridge Scheduler does, the time which is skipped by daylight saving time does not run,
+			// and the time which is repeated runs only once, at the first occurrence
+			if next.Hour() == w.Hour() && next.Minute() == w.Minute() && next.After(t) {
+				return next
+			}
+			w = w.Add(time.Minute)
+		}
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	day := t.Day()
+	lastDay := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	weekday := int(t.Weekday()) + 1
+	switch {
+	case s.dom.days != nil:
+		return s.dom.days[day]
+	case s.dom.last:
+		return day == lastDay
+	case s.dom.lastWeekday:
+		return day == nearestWeekday(t, lastDay)
+	case s.dom.weekday != 0:
+		return day == nearestWeekday(t, s.dom.weekday)
+	case s.dow.days != nil:
+		return s.dow.days[weekday]
+	case s.dow.last != 0:
+		return weekday == s.dow.last && day+7 > lastDay
+	case s.dow.nth != 0:
+		return weekday == s.dow.day && (day-1)/7+1 == s.dow.nth
+	}
+	return false
+}
+
+// nearestWeekday returns the weekday nearest to the day in the month of t without crossing months.
+func nearestWeekday(t time.Time, day int) int {
+	lastDay := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	switch time.Date(t.Year(), t.Month(), day, 0, 0, 0, 0, t.Location()).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == lastDay {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
+
+type rateSchedule struct {
+	interval time.Duration
+}
+
+func parseRate(expr string) (*rateSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid rate expression %q: must be rate(value unit)", expr)
+	}
+	value, err := strconv.Atoi(fields[0])
+	if err != nil || value <= 0 {
+		return nil, fmt.Errorf("invalid rate expression %q: value must be a positive integer", expr)
+	}
+	units := map[string]time.Duration{
+		"minute": time.Minute,
+		"hour":   time.Hour,
+		"day":    24 * time.Hour,
+	}
+	unit := fields[1]
+	if value == 1 && strings.HasSuffix(unit, "s") {
+		return nil, fmt.Errorf("invalid rate expression %q: unit must be singular if value is 1", expr)
+	}
+	if value > 1 {
+		if !strings.HasSuffix(unit, "s") {
+			return nil, fmt.Errorf("invalid rate expression %q: unit must be plural if value is greater than 1", expr)
+		}
+		unit = strings.TrimSuffix(unit, "s")
+	}
+	d, ok := units[unit]
+	if !ok {
+		return nil, fmt.Errorf("invalid rate expression %q: unit must be minute(s), hour(s) or day(s)", expr)
+	}
+	return &rateSchedule{interval: time.Duration(value) * d}, nil
+}
+
+// Next of a rate schedule is relative to t, because rate schedules start at the time they are created.
+func (s *rateSchedule) Next(t time.Time) time.Time {
+	return t.Truncate(time.Minute).Add(s.interval)
+}
+
+type atSchedule struct {
+	year, month, day, hour, min, sec int
+}
+
+const atLayout = "2006-01-02T15:04:05"
+
+func parseAt(expr string) (*atSchedule, error) {
+	t, err := time.Parse(atLayout, expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid at expression %q: must be at(yyyy-mm-ddThh:mm:ss)", expr)
+	}
+	return &atSchedule{
+		year: t.Year(), month: int(t.Month()), day: t.Day(),
+		hour: t.Hour(), min: t.Minute(), sec: t.Second(),
+	}, nil
+}
+
+func (s *atSchedule) Next(t time.Time) time.Time {
+	at := time.Date(s.year, time.Month(s.month), s.day, s.hour, s.min, s.sec, 0, t.Location())
+	if !at.After(t) {
+		return time.Time{}
+	}
+	return at
+}
+
+// IsOneTime reports whether the expression is a one-time schedule (at(...)).
+func IsOneTime(expr string) bool {
+	return strings.HasPrefix(strings.TrimSpace(expr), "at(")
+}
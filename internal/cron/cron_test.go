@@ -0,0 +1,324 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestNext(t *testing.T) {
+	utc := time.UTC
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{
+			name: "every day",
+			expr: "cron(0 10 * * ? *)",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 1, 1, 10, 0, 0, 0, utc),
+		},
+		{
+			name: "the run time itself is excluded",
+			expr: "cron(0 10 * * ? *)",
+			from: time.Date(2024, 1, 1, 10, 0, 0, 0, utc),
+			want: time.Date(2024, 1, 2, 10, 0, 0, 0, utc),
+		},
+		{
+			name: "weekdays by name",
+			expr: "cron(15 10 ? * MON-FRI *)",
+			from: time.Date(2024, 1, 6, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 1, 8, 10, 15, 0, 0, utc),
+		},
+		{
+			name: "day of week 1 is Sunday",
+			expr: "cron(0 0 ? * 1 *)",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 1, 7, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "increment",
+			expr: "cron(0/15 * * * ? *)",
+			from: time.Date(2024, 1, 1, 0, 7, 30, 0, utc),
+			want: time.Date(2024, 1, 1, 0, 15, 0, 0, utc),
+		},
+		{
+			name: "months by name",
+			expr: "cron(0 0 1 JAN,JUL ? *)",
+			from: time.Date(2024, 2, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 7, 1, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "day of month which some months do not have",
+			expr: "cron(0 0 31 * ? *)",
+			from: time.Date(2024, 4, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 5, 31, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "leap day",
+			expr: "cron(0 12 29 2 ? *)",
+			from: time.Date(2024, 3, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2028, 2, 29, 12, 0, 0, 0, utc),
+		},
+		{
+			name: "last day of month",
+			expr: "cron(0 0 L * ? *)",
+			from: time.Date(2024, 2, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 2, 29, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "last weekday of month which ends on Sunday",
+			expr: "cron(0 0 LW * ? *)",
+			from: time.Date(2024, 6, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 6, 28, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "nearest weekday of Saturday is Friday",
+			expr: "cron(0 0 15W * ? *)",
+			from: time.Date(2024, 6, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 6, 14, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "nearest weekday does not cross months",
+			expr: "cron(0 0 1W * ? *)",
+			from: time.Date(2024, 5, 31, 12, 0, 0, 0, utc),
+			want: time.Date(2024, 6, 3, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "nth day of week",
+			expr: "cron(0 0 ? * 6#3 *)",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 1, 19, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "last day of week in month",
+			expr: "cron(0 0 ? * 2L *)",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 1, 29, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "L of day of week is Saturday",
+			expr: "cron(0 0 ? * L *)",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 1, 6, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "year",
+			expr: "cron(0 0 1 1 ? 2026-2027)",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "no run after the year",
+			expr: "cron(0 0 1 1 ? 2023)",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "rate is relative to the time",
+			expr: "rate(5 minutes)",
+			from: time.Date(2024, 1, 1, 0, 7, 30, 0, utc),
+			want: time.Date(2024, 1, 1, 0, 12, 0, 0, utc),
+		},
+		{
+			name: "rate of a day",
+			expr: "rate(1 day)",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 1, 2, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "at",
+			expr: "at(2024-05-01T10:00:00)",
+			from: time.Date(2024, 1, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 5, 1, 10, 0, 0, 0, utc),
+		},
+		{
+			name: "at in the past",
+			expr: "at(2024-05-01T10:00:00)",
+			from: time.Date(2024, 5, 1, 10, 0, 0, 0, utc),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.expr, err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) of %s = %s, want %s", tt.from, tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNextDST follows EventBridge Scheduler: a run time which does not exist when the clock springs forward is skipped,
+// and a run time which occurs twice when the clock falls back runs only once.
+func TestNextDST(t *testing.T) {
+	ny := mustLoadLocation(t, "America/New_York")
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{
+			name: "skipped time on spring forward",
+			expr: "cron(30 2 * * ? *)",
+			from: time.Date(2024, 3, 10, 0, 0, 0, 0, ny),
+			want: time.Date(2024, 3, 11, 2, 30, 0, 0, ny),
+		},
+		{
+			name: "hourly on spring forward",
+			expr: "cron(0 * * * ? *)",
+			from: time.Date(2024, 3, 10, 1, 30, 0, 0, ny),
+			want: time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "repeated time on fall back runs in daylight saving time",
+			expr: "cron(30 1 * * ? *)",
+			from: time.Date(2024, 11, 3, 0, 0, 0, 0, ny),
+			want: time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "repeated time on fall back runs once",
+			expr: "cron(30 1 * * ? *)",
+			from: time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC).In(ny),
+			want: time.Date(2024, 11, 4, 1, 30, 0, 0, ny),
+		},
+		{
+			name: "time in the repeated hour is not before the time",
+			expr: "cron(45 1 * * ? *)",
+			from: time.Date(2024, 11, 3, 6, 40, 0, 0, time.UTC).In(ny),
+			want: time.Date(2024, 11, 4, 1, 45, 0, 0, ny),
+		},
+		{
+			name: "hourly on fall back",
+			expr: "cron(0 * * * ? *)",
+			from: time.Date(2024, 11, 3, 5, 0, 0, 0, time.UTC).In(ny),
+			want: time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.expr, err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) of %s = %s, want %s", tt.from, tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, expr := range []string{
+		"0 0 * * *",
+		"cron(0 0 * * *)",
+		"cron(0 0 * * * *)",
+		"cron(0 0 ? * ? *)",
+		"cron(60 0 * * ? *)",
+		"cron(0 24 * * ? *)",
+		"cron(0 0 5-1 * ? *)",
+		"cron(0 0 ? * 8 *)",
+		"cron(0 0 ? * MON#6 *)",
+		"cron(0 0 32W * ? *)",
+		"cron(0/0 0 * * ? *)",
+		"rate(0 minutes)",
+		"rate(1 minutes)",
+		"rate(2 minute)",
+		"rate(1 week)",
+		"at(2024-01-01)",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := Parse(expr); err == nil {
+				t.Errorf("Parse(%q) returned no error", expr)
+			}
+		})
+	}
+}
+
+func TestFromStandard(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr bool
+	}{
+		{expr: "0 2 * * *", want: "cron(0 2 * * ? *)"},
+		{expr: "*/15 * * * *", want: "cron(0/15 * * * ? *)"},
+		{expr: "0 9 * * 1-5", want: "cron(0 9 ? * 2,3,4,5,6 *)"},
+		{expr: "0 0 * * SUN", want: "cron(0 0 ? * 1 *)"},
+		{expr: "0 0 * * 7", want: "cron(0 0 ? * 1 *)"},
+		{expr: "0 0 * * 0-6", want: "cron(0 0 ? * * *)"},
+		{expr: "0 0 */2 * *", want: "cron(0 0 1/2 * ? *)"},
+		{expr: "0 0 1 */3 *", want: "cron(0 0 1 1/3 ? *)"},
+		{expr: "@daily", want: "cron(0 0 * * ? *)"},
+		{expr: "@weekly", want: "cron(0 0 ? * 1 *)"},
+		{expr: "@yearly", want: "cron(0 0 1 1 ? *)"},
+		{expr: "0 0 1 * 1", wantErr: true},
+		{expr: "0 0 * *", wantErr: true},
+		{expr: "0 0 * * 8", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := FromStandard(tt.expr)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("FromStandard(%q) = %q, want error", tt.expr, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromStandard(%q) returned error: %v", tt.expr, err)
+			}
+			if got != tt.want {
+				t.Errorf("FromStandard(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+			if _, err := Parse(got); err != nil {
+				t.Errorf("converted expression %q is invalid: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		expr          string
+		allowStandard bool
+		want          string
+		wantErr       bool
+	}{
+		{expr: "cron(0 2 * * ? *)", want: "cron(0 2 * * ? *)"},
+		{expr: " rate(1 day) ", want: "rate(1 day)"},
+		{expr: "0 2 * * *", allowStandard: true, want: "cron(0 2 * * ? *)"},
+		{expr: "cron(0 2 * * ? *)", allowStandard: true, want: "cron(0 2 * * ? *)"},
+		{expr: "0 2 * * *", wantErr: true},
+		{expr: "cron(0 2 * * * *)", allowStandard: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := Normalize(tt.expr, tt.allowStandard)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Normalize(%q, %t) = %q, want error", tt.expr, tt.allowStandard, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize(%q, %t) returned error: %v", tt.expr, tt.allowStandard, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q, %t) = %q, want %q", tt.expr, tt.allowStandard, got, tt.want)
+			}
+		})
+	}
+}
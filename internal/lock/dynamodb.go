@@ -0,0 +1,152 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Attributes of lock items. The table must have LockKey (string) as the partition key.
+// ExpiresAt is in unix seconds, so that it can be used as the TTL attribute of the table.
+const (
+	attrKey        = "LockKey"
+	attrID         = "LockId"
+	attrOwner      = "Owner"
+	attrAcquiredAt = "AcquiredAt"
+	attrExpiresAt  = "ExpiresAt"
+)
+
+// DynamoDBLocker stores locks as items of a DynamoDB table, with conditional writes.
+type DynamoDBLocker struct {
+	svc   *dynamodb.Client
+	table string
+}
+
+func NewDynamoDBLocker(svc *dynamodb.Client, table string) *DynamoDBLocker {
+	return &DynamoDBLocker{
+		svc:   svc,
+		table: table,
+	}
+}
+
+func (dl *DynamoDBLocker) Acquire(ctx context.Context, key string, owner string, ttl time.Duration) (*Lock, error) {
+	l, err := newLock(key, owner, ttl)
+	if err != nil {
+		return nil, err
+	}
+	_, err = dl.svc.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: &dl.table,
+		Item: map[string]types.AttributeValue{
+			attrKey:        &types.AttributeValueMemberS{Value: l.Key},
+			attrID:         &types.AttributeValueMemberS{Value: l.ID},
+			attrOwner:      &types.AttributeValueMemberS{Value: l.Owner},
+			attrAcquiredAt: &types.AttributeValueMemberN{Value: strconv.FormatInt(l.AcquiredAt.Unix(), 10)},
+			attrExpiresAt:  &types.AttributeValueMemberN{Value: strconv.FormatInt(l.ExpiresAt.Unix(), 10)},
+		},
+		// Expired locks are overwritten, since the TTL of DynamoDB does not delete items immediately
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR #expiresAt <= :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#key":       attrKey,
+			"#expiresAt": attrExpiresAt,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(l.AcquiredAt.Unix(), 10)},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var failed *types.ConditionalCheckFailedException
+		if errors.As(err, &failed) {
+			return nil, &LockedError{Lock: lockFromItem(failed.Item)}
+		}
+		return nil, fmt.Errorf("failed to put lock item to %s: %w", dl.table, err)
+	}
+	return l, nil
+}
+
+func (dl *DynamoDBLocker) Release(ctx context.Context, l *Lock) error {
+	_, err := dl.svc.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           &dl.table,
+		Key:                 dl.key(l.Key),
+		ConditionExpression: aws.String("#id = :id"),
+		ExpressionAttributeNames: map[string]string{
+			"#id": attrID,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: l.ID},
+		},
+	})
+	if err != nil {
+		var failed *types.ConditionalCheckFailedException
+		if errors.As(err, &failed) {
+			return fmt.Errorf("lock %s is not held by %s anymore", l.Key, l.Owner)
+		}
+		return fmt.Errorf("failed to delete lock item from %s: %w", dl.table, err)
+	}
+	return nil
+}
+
+func (dl *DynamoDBLocker) Get(ctx context.Context, key string) (*Lock, error) {
+	res, err := dl.svc.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      &dl.table,
+		Key:            dl.key(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lock item from %s: %w", dl.table, err)
+	}
+	if len(res.Item) == 0 {
+		return nil, nil
+	}
+	return lockFromItem(res.Item), nil
+}
+
+func (dl *DynamoDBLocker) ForceRelease(ctx context.Context, key string) error {
+	_, err := dl.svc.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: &dl.table,
+		Key:       dl.key(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete lock item from %s: %w", dl.table, err)
+	}
+	return nil
+}
+
+func (dl *DynamoDBLocker) key(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		attrKey: &types.AttributeValueMemberS{Value: key},
+	}
+}
+
+func lockFromItem(item map[string]types.AttributeValue) *Lock {
+	if len(item) == 0 {
+		return nil
+	}
+	str := func(name string) string {
+		if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+			return v.Value
+		}
+		return ""
+	}
+	unix := func(name string) time.Time {
+		if v, ok := item[name].(*types.AttributeValueMemberN); ok {
+			if sec, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+				return time.Unix(sec, 0)
+			}
+		}
+		return time.Time{}
+	}
+	return &Lock{
+		Key:        str(attrKey),
+		Owner:      str(attrOwner),
+		ID:         str(attrID),
+		AcquiredAt: unix(attrAcquiredAt),
+		ExpiresAt:  unix(attrExpiresAt),
+	}
+}
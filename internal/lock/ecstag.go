@@ -0,0 +1,161 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	// tagKeyPrefix is the prefix of the keys of the lock tags
+	tagKeyPrefix   = "fargate-td-lock:"
+	maxTagKeyLen   = 128
+	maxTagValueLen = 256
+)
+
+// ECSTagLocker stores locks as tags of an ECS resource, such as the service which is deployed.
+// Tags cannot be written conditionally, so the lock is verified by reading the tag again after writing it.
+// Concurrent deploys are detected on a best-effort basis.
+type ECSTagLocker struct {
+	svc         *ecs.Client
+	resourceArn string
+}
+
+func NewECSTagLocker(svc *ecs.Client, resourceArn string) *ECSTagLocker {
+	return &ECSTagLocker{
+		svc:         svc,
+		resourceArn: resourceArn,
+	}
+}
+
+func (tl *ECSTagLocker) Acquire(ctx context.Context, key string, owner string, ttl time.Duration) (*Lock, error) {
+	current, err := tl.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && !current.Expired(time.Now()) {
+		return nil, &LockedError{Lock: current}
+	}
+	l, err := newLock(key, sanitizeTagValue(owner), ttl)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tl.svc.TagResource(ctx, &ecs.TagResourceInput{
+		ResourceArn: &tl.resourceArn,
+		Tags: []types.Tag{{
+			Key:   aws.String(tagKey(key)),
+			Value: aws.String(tagValue(l)),
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to tag lock to %s: %w", tl.resourceArn, err)
+	}
+	// The last writer wins, so the other owner may have overwritten the lock
+	current, err = tl.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || current.ID != l.ID {
+		return nil, &LockedError{Lock: current}
+	}
+	return l, nil
+}
+
+func (tl *ECSTagLocker) Release(ctx context.Context, l *Lock) error {
+	current, err := tl.Get(ctx, l.Key)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.ID != l.ID {
+		return fmt.Errorf("lock %s is not held by %s anymore", l.Key, l.Owner)
+	}
+	return tl.ForceRelease(ctx, l.Key)
+}
+
+func (tl *ECSTagLocker) Get(ctx context.Context, key string) (*Lock, error) {
+	res, err := tl.svc.ListTagsForResource(ctx, &ecs.ListTagsForResourceInput{
+		ResourceArn: &tl.resourceArn,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", tl.resourceArn, err)
+	}
+	k := tagKey(key)
+	for _, t := range res.Tags {
+		if aws.ToString(t.Key) == k {
+			return parseTagValue(key, aws.ToString(t.Value))
+		}
+	}
+	return nil, nil
+}
+
+func (tl *ECSTagLocker) ForceRelease(ctx context.Context, key string) error {
+	_, err := tl.svc.UntagResource(ctx, &ecs.UntagResourceInput{
+		ResourceArn: &tl.resourceArn,
+		TagKeys:     []string{tagKey(key)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to untag lock from %s: %w", tl.resourceArn, err)
+	}
+	return nil
+}
+
+func tagKey(key string) string {
+	k := tagKeyPrefix + sanitizeTagValue(key)
+	if len(k) > maxTagKeyLen {
+		k = k[:maxTagKeyLen]
+	}
+	return k
+}
+
+// tagValue returns the value of the lock tag, "ID ACQUIRED_AT EXPIRES_AT OWNER" with times in unix seconds.
+func tagValue(l *Lock) string {
+	v := fmt.Sprintf("%s %d %d %s", l.ID, l.AcquiredAt.Unix(), l.ExpiresAt.Unix(), l.Owner)
+	if len(v) > maxTagValueLen {
+		v = v[:maxTagValueLen]
+	}
+	return v
+}
+
+func parseTagValue(key, v string) (*Lock, error) {
+	fields := strings.SplitN(v, " ", 4)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("invalid lock tag value %q", v)
+	}
+	acquiredAt, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lock tag value %q: %w", v, err)
+	}
+	expiresAt, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lock tag value %q: %w", v, err)
+	}
+	l := &Lock{
+		Key:        key,
+		ID:         fields[0],
+		AcquiredAt: time.Unix(acquiredAt, 0),
+		ExpiresAt:  time.Unix(expiresAt, 0),
+	}
+	if len(fields) == 4 {
+		l.Owner = fields[3]
+	}
+	return l, nil
+}
+
+// sanitizeTagValue replaces the characters which are not allowed in tags with "_".
+func sanitizeTagValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune(" +-=._:/@", r):
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,102 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileLocker stores locks as files in a local directory.
+// It only prevents concurrent deploys on the same machine, and is mainly for tests.
+type FileLocker struct {
+	dir string
+}
+
+func NewFileLocker(dir string) *FileLocker {
+	return &FileLocker{dir: dir}
+}
+
+func (fl *FileLocker) Acquire(ctx context.Context, key string, owner string, ttl time.Duration) (*Lock, error) {
+	l, err := newLock(key, owner, ttl)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal lock: %w", err)
+	}
+	if err := os.MkdirAll(fl.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory %s: %w", fl.dir, err)
+	}
+	path := fl.path(key)
+	// Retry once after removing the expired lock
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			if _, err := f.Write(b); err != nil {
+				_ = f.Close()
+				_ = os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock file %s: %w", path, err)
+			}
+			return l, f.Close()
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file %s: %w", path, err)
+		}
+		current, err := fl.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if current != nil && !current.Expired(time.Now()) {
+			return nil, &LockedError{Lock: current}
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove expired lock file %s: %w", path, err)
+		}
+	}
+	return nil, &LockedError{}
+}
+
+func (fl *FileLocker) Release(ctx context.Context, l *Lock) error {
+	current, err := fl.Get(ctx, l.Key)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.ID != l.ID {
+		return fmt.Errorf("lock %s is not held by %s anymore", l.Key, l.Owner)
+	}
+	return fl.ForceRelease(ctx, l.Key)
+}
+
+func (fl *FileLocker) Get(ctx context.Context, key string) (*Lock, error) {
+	path := fl.path(key)
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file %s: %w", path, err)
+	}
+	l := &Lock{}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %w", path, err)
+	}
+	return l, nil
+}
+
+func (fl *FileLocker) ForceRelease(ctx context.Context, key string) error {
+	path := fl.path(key)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove lock file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (fl *FileLocker) path(key string) string {
+	return filepath.Join(fl.dir, url.PathEscape(key)+".json")
+}
@@ -0,0 +1,103 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFileLocker(t *testing.T) {
+	ctx := context.Background()
+	const key = "/app1/production/web"
+
+	t.Run("acquire", func(t *testing.T) {
+		fl := NewFileLocker(t.TempDir())
+		l, err := fl.Acquire(ctx, key, "alice", time.Minute)
+		if err != nil {
+			t.Fatalf("Acquire() error: %v", err)
+		}
+		if l.Key != key || l.Owner != "alice" || l.ID == "" || !l.ExpiresAt.Equal(l.AcquiredAt.Add(time.Minute)) {
+			t.Errorf("Acquire() = %+v", l)
+		}
+		current, err := fl.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get() error: %v", err)
+		}
+		if current == nil || current.ID != l.ID || current.Owner != "alice" {
+			t.Errorf("Get() = %+v, want %+v", current, l)
+		}
+		if other, err := fl.Get(ctx, "/app1/staging/web"); err != nil || other != nil {
+			t.Errorf("Get() of other key = %+v, %v, want nil", other, err)
+		}
+	})
+
+	t.Run("contention", func(t *testing.T) {
+		fl := NewFileLocker(t.TempDir())
+		l, err := fl.Acquire(ctx, key, "alice", time.Minute)
+		if err != nil {
+			t.Fatalf("Acquire() error: %v", err)
+		}
+		_, err = fl.Acquire(ctx, key, "bob", time.Minute)
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			t.Fatalf("Acquire() error = %v, want LockedError", err)
+		}
+		if locked.Lock == nil || locked.Lock.ID != l.ID || locked.Lock.Owner != "alice" {
+			t.Errorf("LockedError.Lock = %+v, want %+v", locked.Lock, l)
+		}
+		// The lock of the other key is independent
+		if _, err := fl.Acquire(ctx, "/app1/staging/web", "bob", time.Minute); err != nil {
+			t.Errorf("Acquire() of other key error: %v", err)
+		}
+	})
+
+	t.Run("expiry takeover", func(t *testing.T) {
+		fl := NewFileLocker(t.TempDir())
+		expired, err := fl.Acquire(ctx, key, "alice", -time.Minute)
+		if err != nil {
+			t.Fatalf("Acquire() error: %v", err)
+		}
+		l, err := fl.Acquire(ctx, key, "bob", time.Minute)
+		if err != nil {
+			t.Fatalf("Acquire() of expired lock error: %v", err)
+		}
+		if l.ID == expired.ID || l.Owner != "bob" {
+			t.Errorf("Acquire() = %+v, want new lock of bob", l)
+		}
+		if err := fl.Release(ctx, expired); err == nil {
+			t.Error("Release() of the taken over lock succeeded")
+		}
+		if current, _ := fl.Get(ctx, key); current == nil || current.ID != l.ID {
+			t.Errorf("Get() = %+v, want %+v", current, l)
+		}
+	})
+
+	t.Run("release", func(t *testing.T) {
+		fl := NewFileLocker(t.TempDir())
+		l, err := fl.Acquire(ctx, key, "alice", time.Minute)
+		if err != nil {
+			t.Fatalf("Acquire() error: %v", err)
+		}
+		wrong := *l
+		wrong.ID = "other"
+		if err := fl.Release(ctx, &wrong); err == nil {
+			t.Error("Release() by wrong ID succeeded")
+		}
+		if current, _ := fl.Get(ctx, key); current == nil || current.ID != l.ID {
+			t.Fatalf("Get() after release by wrong ID = %+v, want %+v", current, l)
+		}
+		if err := fl.Release(ctx, l); err != nil {
+			t.Fatalf("Release() error: %v", err)
+		}
+		if current, err := fl.Get(ctx, key); err != nil || current != nil {
+			t.Errorf("Get() after release = %+v, %v, want nil", current, err)
+		}
+		if err := fl.Release(ctx, l); err == nil {
+			t.Error("Release() of released lock succeeded")
+		}
+		if _, err := fl.Acquire(ctx, key, "bob", time.Minute); err != nil {
+			t.Errorf("Acquire() after release error: %v", err)
+		}
+	})
+}
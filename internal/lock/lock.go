@@ -0,0 +1,64 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Lock is the lock of a deploy target, such as a task of a target path.
+type Lock struct {
+	Key   string `json:"key"`
+	Owner string `json:"owner"`
+	// ID identifies the acquisition, so that a lock acquired again by the same owner is not released by mistake
+	ID         string    `json:"id"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// Expired reports whether the lock is expired at the time.
+func (l *Lock) Expired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
+
+// LockedError is returned when the lock is held by another owner.
+type LockedError struct {
+	Lock *Lock
+}
+
+func (e *LockedError) Error() string {
+	if e.Lock == nil {
+		return "locked by another owner"
+	}
+	return fmt.Sprintf("locked by %s since %s until %s", e.Lock.Owner, e.Lock.AcquiredAt.Format(time.RFC3339), e.Lock.ExpiresAt.Format(time.RFC3339))
+}
+
+// Locker acquires and releases locks on a backend.
+type Locker interface {
+	// Acquire acquires the lock of the key, or returns *LockedError if it is held by another owner and not expired.
+	Acquire(ctx context.Context, key string, owner string, ttl time.Duration) (*Lock, error)
+	// Release releases the lock, if it is still held by the acquisition.
+	Release(ctx context.Context, l *Lock) error
+	// Get returns the lock of the key, or nil if it is not locked. Expired locks are returned as well.
+	Get(ctx context.Context, key string) (*Lock, error)
+	// ForceRelease releases the lock of the key regardless of its owner.
+	ForceRelease(ctx context.Context, key string) error
+}
+
+func newLock(key, owner string, ttl time.Duration) (*Lock, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate lock id: %w", err)
+	}
+	// Backends store times in seconds
+	now := time.Now().Truncate(time.Second)
+	return &Lock{
+		Key:        key,
+		Owner:      owner,
+		ID:         hex.EncodeToString(b),
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}, nil
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+// DefaultRate is the default number of API calls per second, which is well below the throttling limits of ECS.
+const DefaultRate = 10
+
+// Limiter spaces API calls evenly, so that goroutines which share it do not get throttled.
+type Limiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+// New returns the limiter which allows the rate of calls per second.
+func New(rate float64) *Limiter {
+	return &Limiter{
+		interval: time.Duration(float64(time.Second) / rate),
+	}
+}
+
+// Wait waits until the next call is allowed, or the context is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AddMiddleware adds the middleware which waits for the limiter before each API call.
+// It is used as an APIOptions of AWS clients, such as
+// ecs.NewFromConfig(cfg, func(o *ecs.Options) { o.APIOptions = append(o.APIOptions, l.AddMiddleware) }).
+func (l *Limiter) AddMiddleware(stack *middleware.Stack) error {
+	return Middleware(l)(stack)
+}
+
+// Waiter waits until the next call is allowed, such as Limiter.
+type Waiter interface {
+	Wait(ctx context.Context) error
+}
+
+// Middleware returns the APIOptions of AWS clients which waits for w before each API call.
+func Middleware(w Waiter) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("RateLimit",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				if err := w.Wait(ctx); err != nil {
+					return middleware.InitializeOutput{}, middleware.Metadata{}, err
+				}
+				return next.HandleInitialize(ctx, in)
+			},
+		), middleware.Before)
+	}
+}
@@ -0,0 +1,148 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// DefaultPatterns are the patterns of the names of environment variables which are always masked.
+var DefaultPatterns = []string{"*_SECRET", "*_TOKEN", "*_PASSWORD", "PASSWORD"}
+
+// MinContainedLength is the minimum length of sensitive values which are masked when they are contained in other values.
+// Shorter values, such as "1", "true" or "prod", are masked only when they are equal to the whole value,
+// since they are contained in many unrelated values.
+const MinContainedLength = 8
+
+// Redactor masks the values of sensitive environment variables.
+// A nil Redactor masks nothing.
+type Redactor struct {
+	patterns []string
+	values   []string
+}
+
+// New returns the redactor which masks the values of environment variables
+// whose names match the patterns (case-insensitive), in addition to DefaultPatterns,
+// or whose values equal one of the sensitive values, or contain one of at least MinContainedLength.
+func New(patterns []string, values []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, p := range append(append([]string{}, DefaultPatterns...), patterns...) {
+		p = strings.ToUpper(p)
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %s: %w", p, err)
+		}
+		r.patterns = append(r.patterns, p)
+	}
+	for _, v := range values {
+		if v != "" {
+			r.values = append(r.values, v)
+		}
+	}
+	return r, nil
+}
+
+// maskKey is the key of the hashes of masked values.
+// It is random per process, so that values can not be guessed from their hashes.
+var maskKey = newMaskKey()
+
+func newMaskKey() []byte {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("failed to generate mask key: %s", err))
+	}
+	return key
+}
+
+// Mask returns the masked value, which keeps a short keyed hash of the value so that changes are still visible.
+// The hashes are comparable only within the same process.
+func Mask(value string) string {
+	mac := hmac.New(sha256.New, maskKey)
+	mac.Write([]byte(value))
+	return "[redacted:" + hex.EncodeToString(mac.Sum(nil))[:8] + "]"
+}
+
+// Sensitive returns whether the value of the environment variable should be masked.
+func (r *Redactor) Sensitive(name, value string) bool {
+	if r == nil {
+		return false
+	}
+	upper := strings.ToUpper(name)
+	for _, p := range r.patterns {
+		if ok, _ := path.Match(p, upper); ok {
+			return true
+		}
+	}
+	for _, v := range r.values {
+		if value == v || (len(v) >= MinContainedLength && strings.Contains(value, v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// TaskDefinition returns the copy of the task definition whose sensitive environment values are masked.
+func (r *Redactor) TaskDefinition(in *ecs.RegisterTaskDefinitionInput) *ecs.RegisterTaskDefinitionInput {
+	if r == nil || in == nil {
+		return in
+	}
+	out := *in
+	out.ContainerDefinitions = make([]types.ContainerDefinition, len(in.ContainerDefinitions))
+	for i, cd := range in.ContainerDefinitions {
+		if len(cd.Environment) != 0 {
+			env := make([]types.KeyValuePair, len(cd.Environment))
+			for j, kv := range cd.Environment {
+				if kv.Value != nil && r.Sensitive(aws.ToString(kv.Name), *kv.Value) {
+					kv.Value = aws.String(Mask(*kv.Value))
+				}
+				env[j] = kv
+			}
+			cd.Environment = env
+		}
+		out.ContainerDefinitions[i] = cd
+	}
+	return &out
+}
+
+// YAML returns the task definition YAML whose sensitive environment values are masked.
+// Keys are matched case-insensitively, since the task files are not normalized yet.
+func (r *Redactor) YAML(s string) (string, error) {
+	if r == nil {
+		return s, nil
+	}
+	node, err := yaml.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse task definition yaml: %w", err)
+	}
+	for _, cd := range mappingValue(node.YNode(), "containerDefinitions").Content {
+		for _, kv := range mappingValue(cd, "environment").Content {
+			name, value := mappingValue(kv, "name"), mappingValue(kv, "value")
+			if value.Kind == yaml.ScalarNode && r.Sensitive(name.Value, value.Value) {
+				value.Value = Mask(value.Value)
+				value.Tag = yaml.NodeTagString
+				value.Style = yaml.DoubleQuotedStyle
+			}
+		}
+	}
+	return node.String()
+}
+
+// mappingValue returns the value of the key in the mapping node, or an empty node if it is not found.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n != nil && n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if strings.EqualFold(n.Content[i].Value, key) {
+				return n.Content[i+1]
+			}
+		}
+	}
+	return &yaml.Node{}
+}
@@ -0,0 +1,29 @@
+package redact
+
+import "testing"
+
+func TestSensitive(t *testing.T) {
+	r, err := New(nil, []string{"1", "prod", "s3cr3t-passw0rd"})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "WORKERS", value: "10", want: false},
+		{name: "APP_ENV", value: "production", want: false},
+		{name: "FEATURE_FLAG", value: "1", want: true},
+		{name: "STAGE", value: "prod", want: true},
+		{name: "DATABASE_URL", value: "postgres://app:s3cr3t-passw0rd@db:5432/app", want: true},
+		{name: "DB_PASSWORD", value: "x", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Sensitive(tt.name, tt.value); got != tt.want {
+				t.Errorf("Sensitive(%q, %q) = %t, want %t", tt.name, tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kazz187/fargate-td/internal/taskdef"
+)
+
+// Formats of reports.
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+)
+
+// Kinds of targets.
+const (
+	KindService = "service"
+	KindCronJob = "cronJob"
+)
+
+// Statuses of targets.
+const (
+	StatusUpToDate = "up-to-date"
+	StatusChanged  = "changed"
+	StatusCreated  = "created"
+	StatusError    = "error"
+)
+
+// Report is the report of the task definition changes of targets.
+type Report struct {
+	Title   string    `json:"title"`
+	Targets []*Target `json:"targets"`
+}
+
+// Target is a service or a cron job which uses the task definition.
+type Target struct {
+	Kind                     string                `json:"kind"`
+	Cluster                  string                `json:"cluster"`
+	Name                     string                `json:"name"`
+	Path                     string                `json:"path,omitempty"`
+	Task                     string                `json:"task,omitempty"`
+	Status                   string                `json:"status"`
+	CurrentTaskDefinitionArn string                `json:"currentTaskDefinitionArn,omitempty"`
+	NewTaskDefinitionArn     string                `json:"newTaskDefinitionArn,omitempty"`
+	Summary                  []string              `json:"summary,omitempty"`
+	Changes                  []taskdef.FieldChange `json:"changes,omitempty"`
+	Diff                     string                `json:"diff,omitempty"`
+	Notes                    []Note                `json:"notes,omitempty"`
+}
+
+// Note is a change or a problem of the target other than the task definition.
+type Note struct {
+	Message string `json:"message"`
+	Diff    string `json:"diff,omitempty"`
+}
+
+func New(title string) *Report {
+	return &Report{
+		Title:   title,
+		Targets: []*Target{},
+	}
+}
+
+// Add adds the target which is up-to-date until changes are added.
+func (r *Report) Add(kind, cluster, name string) *Target {
+	t := &Target{
+		Kind:    kind,
+		Cluster: cluster,
+		Name:    name,
+		Status:  StatusUpToDate,
+	}
+	r.Targets = append(r.Targets, t)
+	return t
+}
+
+// AddChange adds the change of the task definition.
+func (t *Target) AddChange(change *taskdef.Change) {
+	if change == nil {
+		return
+	}
+	t.setStatus(StatusChanged)
+	t.Summary = append(t.Summary, change.Summary...)
+	t.Changes = append(t.Changes, change.Fields...)
+	if t.Diff != "" {
+		t.Diff += "\n"
+	}
+	t.Diff += change.Diff
+}
+
+// AddNote adds the change which is not of the task definition.
+func (t *Target) AddNote(status, message, diff string) {
+	t.setStatus(status)
+	t.Notes = append(t.Notes, Note{
+		Message: message,
+		Diff:    diff,
+	})
+}
+
+// SetError marks the target as failed to be checked.
+func (t *Target) SetError(err error) {
+	t.Status = StatusError
+	t.Notes = append(t.Notes, Note{Message: err.Error()})
+}
+
+func (t *Target) setStatus(status string) {
+	// Error and creation are more important than changes
+	if t.Status == StatusUpToDate || t.Status == StatusChanged {
+		t.Status = status
+	}
+}
+
+// Label returns the label of the target, such as "[cluster: c, service: s]".
+func (t *Target) Label() string {
+	var parts []string
+	if t.Path != "" {
+		parts = append(parts, "path: "+t.Path)
+	}
+	if t.Task != "" {
+		parts = append(parts, "task: "+t.Task)
+	}
+	parts = append(parts, "cluster: "+t.Cluster, t.Kind+": "+t.Name)
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// WriteFile writes the report to the file in the format.
+func (r *Report) WriteFile(path string, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file %s: %w", path, err)
+	}
+	if err := r.Write(f, format); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadFile reads the report which is written to the file in JSON.
+func ReadFile(path string) (*Report, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report file %s: %w", path, err)
+	}
+	r := &Report{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("failed to parse report file %s as %s: %w", path, FormatJSON, err)
+	}
+	return r, nil
+}
+
+// Write writes the report in the format.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatText:
+		return r.writeText(w)
+	case FormatMarkdown:
+		return r.writeMarkdown(w)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	return fmt.Errorf("invalid report format %s", format)
+}
+
+func (r *Report) writeText(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n", r.Title)
+	for _, t := range r.Targets {
+		fmt.Fprintf(&sb, "\n%s %s\n", t.Label(), t.Status)
+		if t.CurrentTaskDefinitionArn != "" {
+			fmt.Fprintf(&sb, "current: %s\n", t.CurrentTaskDefinitionArn)
+		}
+		if t.NewTaskDefinitionArn != "" {
+			fmt.Fprintf(&sb, "new: %s\n", t.NewTaskDefinitionArn)
+		}
+		for _, n := range t.Notes {
+			fmt.Fprintf(&sb, "- %s\n", n.Message)
+			if n.Diff != "" {
+				fmt.Fprintf(&sb, "%s\n", n.Diff)
+			}
+		}
+		for _, s := range t.Summary {
+			fmt.Fprintf(&sb, "  %s\n", s)
+		}
+		if t.Diff != "" {
+			fmt.Fprintf(&sb, "%s\n", t.Diff)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeMarkdown writes the report with collapsible sections per cluster and target, for pull request comments.
+func (r *Report) writeMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## %s\n\n", r.Title)
+	var clusters []string
+	byCluster := map[string][]*Target{}
+	for _, t := range r.Targets {
+		if _, ok := byCluster[t.Cluster]; !ok {
+			clusters = append(clusters, t.Cluster)
+		}
+		byCluster[t.Cluster] = append(byCluster[t.Cluster], t)
+	}
+	if len(clusters) == 0 {
+		sb.WriteString("No target.\n")
+	}
+	for _, cluster := range clusters {
+		targets := byCluster[cluster]
+		changed := 0
+		for _, t := range targets {
+			if t.Status != StatusUpToDate {
+				changed++
+			}
+		}
+		fmt.Fprintf(&sb, "<details>\n<summary>cluster: <code>%s</code> (%d of %d changed)</summary>\n\n", cluster, changed, len(targets))
+		for _, t := range targets {
+			name := t.Name
+			if t.Task != "" {
+				name = t.Task + " → " + name
+			}
+			fmt.Fprintf(&sb, "<details>\n<summary>%s: <code>%s</code> — %s</summary>\n\n", t.Kind, name, t.Status)
+			if t.Path != "" {
+				fmt.Fprintf(&sb, "- path: `%s`\n", t.Path)
+			}
+			if t.CurrentTaskDefinitionArn != "" {
+				fmt.Fprintf(&sb, "- current: `%s`\n", t.CurrentTaskDefinitionArn)
+			}
+			if t.NewTaskDefinitionArn != "" {
+				fmt.Fprintf(&sb, "- new: `%s`\n", t.NewTaskDefinitionArn)
+			}
+			for _, n := range t.Notes {
+				fmt.Fprintf(&sb, "- %s\n", n.Message)
+			}
+			for _, s := range t.Summary {
+				fmt.Fprintf(&sb, "- %s\n", s)
+			}
+			for _, n := range t.Notes {
+				if n.Diff != "" {
+					fmt.Fprintf(&sb, "\n```diff\n%s\n```\n", n.Diff)
+				}
+			}
+			if t.Diff != "" {
+				fmt.Fprintf(&sb, "\n```diff\n%s\n```\n", t.Diff)
+			}
+			sb.WriteString("\n</details>\n\n")
+		}
+		sb.WriteString("</details>\n\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,99 @@
+package taskdef
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"gopkg.in/yaml.v3"
+)
+
+// FieldChange is the change of a field of the task definition.
+// The path is in the same shape as the YAML, such as "containerDefinitions[web].image".
+// From is nil if the field is added, and To is nil if the field is removed.
+type FieldChange struct {
+	Path string  `json:"path"`
+	From *string `json:"from"`
+	To   *string `json:"to"`
+}
+
+// FieldChanges returns the changes of the fields from a task definition to another.
+func FieldChanges(from, to *ecs.RegisterTaskDefinitionInput) []FieldChange {
+	fromFields := flattenNode(yamlNode(reflect.ValueOf(Normalize(from))))
+	toFields := flattenNode(yamlNode(reflect.ValueOf(Normalize(to))))
+	fromValues := map[string]string{}
+	for _, f := range fromFields {
+		fromValues[f.path] = f.value
+	}
+	toValues := map[string]string{}
+	var changes []FieldChange
+	for _, f := range toFields {
+		toValues[f.path] = f.value
+		v, ok := fromValues[f.path]
+		if ok && v == f.value {
+			continue
+		}
+		change := FieldChange{
+			Path: f.path,
+			To:   &f.value,
+		}
+		if ok {
+			change.From = &v
+		}
+		changes = append(changes, change)
+	}
+	for _, f := range fromFields {
+		if _, ok := toValues[f.path]; !ok {
+			changes = append(changes, FieldChange{
+				Path: f.path,
+				From: &f.value,
+			})
+		}
+	}
+	return changes
+}
+
+type field struct {
+	path  string
+	value string
+}
+
+// flattenNode returns the scalar values of the node with their paths, in the order of the node.
+func flattenNode(node *yaml.Node) []field {
+	var fields []field
+	var visit func(n *yaml.Node, path string)
+	visit = func(n *yaml.Node, path string) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i].Value
+				if path != "" {
+					key = path + "." + key
+				}
+				visit(n.Content[i+1], key)
+			}
+		case yaml.SequenceNode:
+			for i, e := range n.Content {
+				// Elements which have a name are identified by the name, so that reordering is not reported
+				visit(e, fmt.Sprintf("%s[%s]", path, elementKey(e, i)))
+			}
+		case yaml.ScalarNode:
+			fields = append(fields, field{path: path, value: n.Value})
+		}
+	}
+	if node != nil {
+		visit(node, "")
+	}
+	return fields
+}
+
+func elementKey(n *yaml.Node, index int) string {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == "name" {
+				return n.Content[i+1].Value
+			}
+		}
+	}
+	return fmt.Sprint(index)
+}
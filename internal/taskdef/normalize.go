@@ -0,0 +1,125 @@
+package taskdef
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// Default values which ECS fills in when the task definition is registered.
+const (
+	defaultHealthCheckInterval = 30
+	defaultHealthCheckTimeout  = 5
+	defaultHealthCheckRetries  = 3
+)
+
+// Normalize returns a copy of the task definition in which the values ECS fills in by default are set,
+// and the lists whose order is not meaningful are sorted.
+// in is not modified.
+func Normalize(in *ecs.RegisterTaskDefinitionInput) *ecs.RegisterTaskDefinitionInput {
+	if in == nil {
+		return nil
+	}
+	out := *in
+	if out.NetworkMode == "" {
+		out.NetworkMode = types.NetworkModeBridge
+	}
+	out.RequiresCompatibilities = sortedCopy(in.RequiresCompatibilities, func(a, b types.Compatibility) bool {
+		return a < b
+	})
+	out.Tags = sortedCopy(in.Tags, func(a, b types.Tag) bool {
+		return aws.ToString(a.Key) < aws.ToString(b.Key)
+	})
+	out.Volumes = make([]types.Volume, len(in.Volumes))
+	for i, v := range in.Volumes {
+		if v.Host != nil && v.Host.SourcePath == nil {
+			// Bind mounts without source path are described with empty host
+			v.Host = nil
+		}
+		out.Volumes[i] = v
+	}
+	out.ContainerDefinitions = make([]types.ContainerDefinition, len(in.ContainerDefinitions))
+	for i, cd := range in.ContainerDefinitions {
+		out.ContainerDefinitions[i] = normalizeContainer(cd, out.NetworkMode)
+	}
+	return &out
+}
+
+func normalizeContainer(cd types.ContainerDefinition, networkMode types.NetworkMode) types.ContainerDefinition {
+	if cd.Essential == nil {
+		cd.Essential = aws.Bool(true)
+	}
+	cd.Environment = sortedCopy(cd.Environment, func(a, b types.KeyValuePair) bool {
+		return aws.ToString(a.Name) < aws.ToString(b.Name)
+	})
+	cd.Secrets = sortedCopy(cd.Secrets, func(a, b types.Secret) bool {
+		return aws.ToString(a.Name) < aws.ToString(b.Name)
+	})
+
+	portMappings := make([]types.PortMapping, len(cd.PortMappings))
+	for i, pm := range cd.PortMappings {
+		if pm.Protocol == "" {
+			pm.Protocol = types.TransportProtocolTcp
+		}
+		if pm.HostPort == nil && pm.ContainerPort != nil {
+			switch networkMode {
+			case types.NetworkModeAwsvpc, types.NetworkModeHost:
+				// The host port must be the same as the container port
+				pm.HostPort = pm.ContainerPort
+			case types.NetworkModeBridge:
+				// A dynamic host port is described as 0
+				pm.HostPort = aws.Int32(0)
+			}
+		}
+		portMappings[i] = pm
+	}
+	cd.PortMappings = portMappings
+
+	mountPoints := make([]types.MountPoint, len(cd.MountPoints))
+	for i, mp := range cd.MountPoints {
+		if mp.ReadOnly == nil {
+			mp.ReadOnly = aws.Bool(false)
+		}
+		mountPoints[i] = mp
+	}
+	cd.MountPoints = mountPoints
+
+	volumesFrom := make([]types.VolumeFrom, len(cd.VolumesFrom))
+	for i, vf := range cd.VolumesFrom {
+		if vf.ReadOnly == nil {
+			vf.ReadOnly = aws.Bool(false)
+		}
+		volumesFrom[i] = vf
+	}
+	cd.VolumesFrom = volumesFrom
+
+	if cd.HealthCheck != nil {
+		hc := *cd.HealthCheck
+		if hc.Interval == nil {
+			hc.Interval = aws.Int32(defaultHealthCheckInterval)
+		}
+		if hc.Timeout == nil {
+			hc.Timeout = aws.Int32(defaultHealthCheckTimeout)
+		}
+		if hc.Retries == nil {
+			hc.Retries = aws.Int32(defaultHealthCheckRetries)
+		}
+		cd.HealthCheck = &hc
+	}
+	return cd
+}
+
+// sortedCopy returns a sorted copy of the list, so that the original list is not modified.
+func sortedCopy[T any](list []T, less func(a, b T) bool) []T {
+	if len(list) == 0 {
+		return nil
+	}
+	sorted := make([]T, len(list))
+	copy(sorted, list)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+	return sorted
+}
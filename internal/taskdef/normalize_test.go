@@ -0,0 +1,204 @@
+package taskdef
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go/document"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   *ecs.RegisterTaskDefinitionInput
+		want *ecs.RegisterTaskDefinitionInput
+	}{
+		{
+			name: "network mode is bridge by default",
+			in:   &ecs.RegisterTaskDefinitionInput{},
+			want: &ecs.RegisterTaskDefinitionInput{NetworkMode: types.NetworkModeBridge},
+		},
+		{
+			name: "defaults of containers",
+			in: &ecs.RegisterTaskDefinitionInput{
+				NetworkMode: types.NetworkModeAwsvpc,
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Name:         aws.String("app"),
+					PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(8080)}},
+					MountPoints:  []types.MountPoint{{SourceVolume: aws.String("data")}},
+					VolumesFrom:  []types.VolumeFrom{{SourceContainer: aws.String("init")}},
+					HealthCheck:  &types.HealthCheck{Command: []string{"CMD", "true"}},
+				}},
+			},
+			want: &ecs.RegisterTaskDefinitionInput{
+				NetworkMode: types.NetworkModeAwsvpc,
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Name:         aws.String("app"),
+					Essential:    aws.Bool(true),
+					PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(8080), HostPort: aws.Int32(8080), Protocol: types.TransportProtocolTcp}},
+					MountPoints:  []types.MountPoint{{SourceVolume: aws.String("data"), ReadOnly: aws.Bool(false)}},
+					VolumesFrom:  []types.VolumeFrom{{SourceContainer: aws.String("init"), ReadOnly: aws.Bool(false)}},
+					HealthCheck: &types.HealthCheck{
+						Command:  []string{"CMD", "true"},
+						Interval: aws.Int32(30),
+						Timeout:  aws.Int32(5),
+						Retries:  aws.Int32(3),
+					},
+				}},
+			},
+		},
+		{
+			name: "dynamic host port of bridge network mode",
+			in: &ecs.RegisterTaskDefinitionInput{
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Essential:    aws.Bool(false),
+					PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80), Protocol: types.TransportProtocolUdp}},
+				}},
+			},
+			want: &ecs.RegisterTaskDefinitionInput{
+				NetworkMode: types.NetworkModeBridge,
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Essential:    aws.Bool(false),
+					PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80), HostPort: aws.Int32(0), Protocol: types.TransportProtocolUdp}},
+				}},
+			},
+		},
+		{
+			name: "lists whose order is not meaningful are sorted",
+			in: &ecs.RegisterTaskDefinitionInput{
+				NetworkMode:             types.NetworkModeAwsvpc,
+				RequiresCompatibilities: []types.Compatibility{types.CompatibilityFargate, types.CompatibilityEc2},
+				Tags:                    []types.Tag{{Key: aws.String("b")}, {Key: aws.String("a")}},
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Essential:   aws.Bool(true),
+					Environment: []types.KeyValuePair{{Name: aws.String("Z")}, {Name: aws.String("A")}},
+					Secrets:     []types.Secret{{Name: aws.String("S2")}, {Name: aws.String("S1")}},
+				}},
+			},
+			want: &ecs.RegisterTaskDefinitionInput{
+				NetworkMode:             types.NetworkModeAwsvpc,
+				RequiresCompatibilities: []types.Compatibility{types.CompatibilityEc2, types.CompatibilityFargate},
+				Tags:                    []types.Tag{{Key: aws.String("a")}, {Key: aws.String("b")}},
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Essential:   aws.Bool(true),
+					Environment: []types.KeyValuePair{{Name: aws.String("A")}, {Name: aws.String("Z")}},
+					Secrets:     []types.Secret{{Name: aws.String("S1")}, {Name: aws.String("S2")}},
+				}},
+			},
+		},
+		{
+			name: "host volume without source path is empty",
+			in: &ecs.RegisterTaskDefinitionInput{
+				NetworkMode: types.NetworkModeAwsvpc,
+				Volumes: []types.Volume{
+					{Name: aws.String("bind"), Host: &types.HostVolumeProperties{}},
+					{Name: aws.String("host"), Host: &types.HostVolumeProperties{SourcePath: aws.String("/data")}},
+				},
+			},
+			want: &ecs.RegisterTaskDefinitionInput{
+				NetworkMode: types.NetworkModeAwsvpc,
+				Volumes: []types.Volume{
+					{Name: aws.String("bind")},
+					{Name: aws.String("host"), Host: &types.HostVolumeProperties{SourcePath: aws.String("/data")}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Normalize(tt.in)
+			if diff := cmp.Diff(tt.want, got, cmpopts.IgnoreTypes(document.NoSerde{}), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Normalize() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNormalizeDoesNotModifyInput(t *testing.T) {
+	in := &ecs.RegisterTaskDefinitionInput{
+		Tags: []types.Tag{{Key: aws.String("b")}, {Key: aws.String("a")}},
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Environment:  []types.KeyValuePair{{Name: aws.String("Z")}, {Name: aws.String("A")}},
+			PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80)}},
+			HealthCheck:  &types.HealthCheck{},
+		}},
+	}
+	Normalize(in)
+	cd := in.ContainerDefinitions[0]
+	if in.NetworkMode != "" || aws.ToString(in.Tags[0].Key) != "b" || aws.ToString(cd.Environment[0].Name) != "Z" ||
+		cd.Essential != nil || cd.PortMappings[0].HostPort != nil || cd.HealthCheck.Interval != nil {
+		t.Errorf("Normalize() modified the input: %+v", in)
+	}
+	if Normalize(nil) != nil {
+		t.Error("Normalize(nil) is not nil")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	registered := &ecs.RegisterTaskDefinitionInput{
+		Family:      aws.String("app"),
+		NetworkMode: types.NetworkModeAwsvpc,
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name:         aws.String("app"),
+			Essential:    aws.Bool(true),
+			Environment:  []types.KeyValuePair{{Name: aws.String("A"), Value: aws.String("1")}, {Name: aws.String("B"), Value: aws.String("2")}},
+			PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80), HostPort: aws.Int32(80), Protocol: types.TransportProtocolTcp}},
+			MountPoints:  []types.MountPoint{},
+		}},
+		Volumes: []types.Volume{},
+	}
+	tests := []struct {
+		name     string
+		rendered *ecs.RegisterTaskDefinitionInput
+		wantDiff bool
+	}{
+		{
+			name: "defaults, order and empty lists are equal",
+			rendered: &ecs.RegisterTaskDefinitionInput{
+				Family:      aws.String("app"),
+				NetworkMode: types.NetworkModeAwsvpc,
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Name:         aws.String("app"),
+					Environment:  []types.KeyValuePair{{Name: aws.String("B"), Value: aws.String("2")}, {Name: aws.String("A"), Value: aws.String("1")}},
+					PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80)}},
+				}},
+			},
+		},
+		{
+			name: "changed value",
+			rendered: &ecs.RegisterTaskDefinitionInput{
+				Family:      aws.String("app"),
+				NetworkMode: types.NetworkModeAwsvpc,
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Name:         aws.String("app"),
+					Environment:  []types.KeyValuePair{{Name: aws.String("A"), Value: aws.String("1")}, {Name: aws.String("B"), Value: aws.String("3")}},
+					PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80)}},
+				}},
+			},
+			wantDiff: true,
+		},
+		{
+			name: "order of containers is meaningful",
+			rendered: &ecs.RegisterTaskDefinitionInput{
+				Family:      aws.String("app"),
+				NetworkMode: types.NetworkModeAwsvpc,
+				ContainerDefinitions: []types.ContainerDefinition{
+					{Name: aws.String("sidecar")},
+					registered.ContainerDefinitions[0],
+				},
+			},
+			wantDiff: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := Diff(registered, tt.rendered); (diff != "") != tt.wantDiff {
+				t.Errorf("Diff() = %q, want diff: %t", diff, tt.wantDiff)
+			}
+		})
+	}
+}
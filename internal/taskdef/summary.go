@@ -0,0 +1,164 @@
+package taskdef
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go/document"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// taskSummaryName is the name of the summary line of the task level changes.
+const taskSummaryName = "(task)"
+
+// Summary returns the short summary of the changes from a task definition to another, a line per container.
+// Values of environment variables and secrets are not shown.
+func Summary(from, to *ecs.RegisterTaskDefinitionInput) []string {
+	from, to = Normalize(from), Normalize(to)
+	var lines []string
+
+	var taskChanges []string
+	taskChanges = appendChange(taskChanges, "cpu", aws.ToString(from.Cpu), aws.ToString(to.Cpu))
+	taskChanges = appendChange(taskChanges, "memory", aws.ToString(from.Memory), aws.ToString(to.Memory))
+	taskChanges = appendChange(taskChanges, "taskRole", aws.ToString(from.TaskRoleArn), aws.ToString(to.TaskRoleArn))
+	taskChanges = appendChange(taskChanges, "executionRole", aws.ToString(from.ExecutionRoleArn), aws.ToString(to.ExecutionRoleArn))
+	taskChanges = appendChange(taskChanges, "networkMode", string(from.NetworkMode), string(to.NetworkMode))
+	if !equal(from.Volumes, to.Volumes) {
+		taskChanges = append(taskChanges, "volumes changed")
+	}
+	if !equal(from.Tags, to.Tags) {
+		taskChanges = append(taskChanges, "tags changed")
+	}
+	if len(taskChanges) != 0 {
+		lines = append(lines, taskSummaryName+": "+strings.Join(taskChanges, ", "))
+	}
+
+	fromContainers := map[string]types.ContainerDefinition{}
+	for _, cd := range from.ContainerDefinitions {
+		fromContainers[aws.ToString(cd.Name)] = cd
+	}
+	toNames := map[string]bool{}
+	for _, cd := range to.ContainerDefinitions {
+		name := aws.ToString(cd.Name)
+		toNames[name] = true
+		current, ok := fromContainers[name]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("%s: added (image %s)", name, aws.ToString(cd.Image)))
+			continue
+		}
+		if changes := containerChanges(current, cd); len(changes) != 0 {
+			lines = append(lines, name+": "+strings.Join(changes, ", "))
+		}
+	}
+	for _, cd := range from.ContainerDefinitions {
+		if name := aws.ToString(cd.Name); !toNames[name] {
+			lines = append(lines, name+": removed")
+		}
+	}
+	return lines
+}
+
+func containerChanges(from, to types.ContainerDefinition) []string {
+	var changes []string
+	changes = appendChange(changes, "image", aws.ToString(from.Image), aws.ToString(to.Image))
+	changes = appendChange(changes, "cpu", fmt.Sprint(from.Cpu), fmt.Sprint(to.Cpu))
+	changes = appendChange(changes, "memory", int32String(from.Memory), int32String(to.Memory))
+	changes = appendChange(changes, "memoryReservation", int32String(from.MemoryReservation), int32String(to.MemoryReservation))
+	changes = appendChange(changes, "command", strings.Join(from.Command, " "), strings.Join(to.Command, " "))
+	changes = appendChange(changes, "entryPoint", strings.Join(from.EntryPoint, " "), strings.Join(to.EntryPoint, " "))
+	if from.Essential != nil && to.Essential != nil {
+		changes = appendChange(changes, "essential", fmt.Sprint(*from.Essential), fmt.Sprint(*to.Essential))
+	}
+
+	fromEnv, toEnv := map[string]string{}, map[string]string{}
+	for _, kv := range from.Environment {
+		fromEnv[aws.ToString(kv.Name)] = aws.ToString(kv.Value)
+	}
+	for _, kv := range to.Environment {
+		toEnv[aws.ToString(kv.Name)] = aws.ToString(kv.Value)
+	}
+	if c := keyChanges(fromEnv, toEnv); c != "" {
+		changes = append(changes, "env "+c)
+	}
+	fromSecrets, toSecrets := map[string]string{}, map[string]string{}
+	for _, s := range from.Secrets {
+		fromSecrets[aws.ToString(s.Name)] = aws.ToString(s.ValueFrom)
+	}
+	for _, s := range to.Secrets {
+		toSecrets[aws.ToString(s.Name)] = aws.ToString(s.ValueFrom)
+	}
+	if c := keyChanges(fromSecrets, toSecrets); c != "" {
+		changes = append(changes, "secrets "+c)
+	}
+	if !equal(from.PortMappings, to.PortMappings) {
+		changes = append(changes, "portMappings changed")
+	}
+	if !equal(from.LogConfiguration, to.LogConfiguration) {
+		changes = append(changes, "logConfiguration changed")
+	}
+	if !equal(from.HealthCheck, to.HealthCheck) {
+		changes = append(changes, "healthCheck changed")
+	}
+
+	if len(changes) == 0 && !equal(from, to) {
+		changes = append(changes, "other settings changed")
+	}
+	return changes
+}
+
+// keyChanges returns the added (+), removed (-) and changed (~) keys, such as "+FOO, ~BAR".
+func keyChanges(from, to map[string]string) string {
+	var changes []string
+	for _, k := range sortedKeys(to) {
+		v, ok := from[k]
+		if !ok {
+			changes = append(changes, "+"+k)
+		} else if v != to[k] {
+			changes = append(changes, "~"+k)
+		}
+	}
+	for _, k := range sortedKeys(from) {
+		if _, ok := to[k]; !ok {
+			changes = append(changes, "-"+k)
+		}
+	}
+	return strings.Join(changes, " ")
+}
+
+func appendChange(changes []string, name string, from, to string) []string {
+	if from == to {
+		return changes
+	}
+	if from == "" {
+		from = "(none)"
+	}
+	if to == "" {
+		to = "(none)"
+	}
+	return append(changes, fmt.Sprintf("%s %s → %s", name, from, to))
+}
+
+func equal(a, b any) bool {
+	return cmp.Equal(a, b, cmpopts.IgnoreTypes(document.NoSerde{}), cmpopts.EquateEmpty())
+}
+
+func int32String(v *int32) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(*v)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
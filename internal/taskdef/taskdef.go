@@ -0,0 +1,105 @@
+package taskdef
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go/document"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// FromTaskDefinition returns the input which registers the same task definition as td.
+func FromTaskDefinition(td *types.TaskDefinition, tags []types.Tag) *ecs.RegisterTaskDefinitionInput {
+	return &ecs.RegisterTaskDefinitionInput{
+		ContainerDefinitions:    td.ContainerDefinitions,
+		Family:                  td.Family,
+		Cpu:                     td.Cpu,
+		EnableFaultInjection:    td.EnableFaultInjection,
+		EphemeralStorage:        td.EphemeralStorage,
+		ExecutionRoleArn:        td.ExecutionRoleArn,
+		InferenceAccelerators:   td.InferenceAccelerators,
+		IpcMode:                 td.IpcMode,
+		Memory:                  td.Memory,
+		NetworkMode:             td.NetworkMode,
+		PidMode:                 td.PidMode,
+		PlacementConstraints:    td.PlacementConstraints,
+		ProxyConfiguration:      td.ProxyConfiguration,
+		RequiresCompatibilities: td.RequiresCompatibilities,
+		RuntimePlatform:         td.RuntimePlatform,
+		Tags:                    tags,
+		TaskRoleArn:             td.TaskRoleArn,
+		Volumes:                 td.Volumes,
+	}
+}
+
+// ToTaskDefinition returns the task definition which in registers as tdArn, without the fields which are set by ECS on registration.
+func ToTaskDefinition(in *ecs.RegisterTaskDefinitionInput, tdArn string) *types.TaskDefinition {
+	return &types.TaskDefinition{
+		TaskDefinitionArn:       &tdArn,
+		ContainerDefinitions:    in.ContainerDefinitions,
+		Family:                  in.Family,
+		Cpu:                     in.Cpu,
+		EnableFaultInjection:    in.EnableFaultInjection,
+		EphemeralStorage:        in.EphemeralStorage,
+		ExecutionRoleArn:        in.ExecutionRoleArn,
+		InferenceAccelerators:   in.InferenceAccelerators,
+		IpcMode:                 in.IpcMode,
+		Memory:                  in.Memory,
+		NetworkMode:             in.NetworkMode,
+		PidMode:                 in.PidMode,
+		PlacementConstraints:    in.PlacementConstraints,
+		ProxyConfiguration:      in.ProxyConfiguration,
+		RequiresCompatibilities: in.RequiresCompatibilities,
+		RuntimePlatform:         in.RuntimePlatform,
+		TaskRoleArn:             in.TaskRoleArn,
+		Volumes:                 in.Volumes,
+	}
+}
+
+// Diff returns the diff from the registered task definition to the rendered one, or "" if they are equal.
+// Both are normalized, so that the defaults of ECS, the order of lists and empty values are not reported.
+func Diff(registered, rendered *ecs.RegisterTaskDefinitionInput) string {
+	return cmp.Diff(Normalize(registered), Normalize(rendered), cmpopts.IgnoreTypes(document.NoSerde{}), cmpopts.EquateEmpty())
+}
+
+// Change is the change from a task definition to another.
+type Change struct {
+	// Summary is the short summary of the changes, a line per container
+	Summary []string
+	// Fields is the changes of the fields
+	Fields []FieldChange
+	// Diff is the unified diff of the task definitions in YAML
+	Diff string
+}
+
+// Compare returns the change from the registered task definition to the rendered one, or nil if they are equal.
+func Compare(registered, rendered *ecs.RegisterTaskDefinitionInput, fromName, toName string) (*Change, error) {
+	if Diff(registered, rendered) == "" {
+		return nil, nil
+	}
+	from, err := YAML(Normalize(registered))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render registered task definition: %w", err)
+	}
+	to, err := YAML(Normalize(rendered))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render new task definition: %w", err)
+	}
+	if from == to {
+		// Differences which are not rendered, such as an empty string and nil, are not changes
+		return nil, nil
+	}
+	return &Change{
+		Summary: Summary(registered, rendered),
+		Fields:  FieldChanges(registered, rendered),
+		Diff:    UnifiedDiff(from, to, fromName, toName),
+	}, nil
+}
+
+// Revision returns "family:revision" of the task definition ARN.
+func Revision(tdArn string) string {
+	return tdArn[strings.LastIndex(tdArn, "/")+1:]
+}
@@ -0,0 +1,113 @@
+package taskdef
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+func TestCompare(t *testing.T) {
+	registered := &ecs.RegisterTaskDefinitionInput{
+		Family:      aws.String("app"),
+		NetworkMode: types.NetworkModeAwsvpc,
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name:  aws.String("web"),
+			Image: aws.String("nginx:1"),
+		}},
+	}
+	tests := []struct {
+		name     string
+		rendered *ecs.RegisterTaskDefinitionInput
+		want     []string
+	}{
+		{
+			name: "equal",
+			rendered: &ecs.RegisterTaskDefinitionInput{
+				Family:      aws.String("app"),
+				NetworkMode: types.NetworkModeAwsvpc,
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Name:  aws.String("web"),
+					Image: aws.String("nginx:1"),
+				}},
+			},
+		},
+		{
+			name: "differences which are not rendered",
+			rendered: &ecs.RegisterTaskDefinitionInput{
+				Family:      aws.String("app"),
+				NetworkMode: types.NetworkModeAwsvpc,
+				Cpu:         aws.String(""),
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Name:       aws.String("web"),
+					Image:      aws.String("nginx:1"),
+					User:       aws.String(""),
+					EntryPoint: []string{},
+				}},
+			},
+		},
+		{
+			name: "changed",
+			rendered: &ecs.RegisterTaskDefinitionInput{
+				Family:      aws.String("app"),
+				NetworkMode: types.NetworkModeAwsvpc,
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Name:  aws.String("web"),
+					Image: aws.String("nginx:2"),
+				}},
+			},
+			want: []string{"--- app:1\n+++ app:2\n", "-    image: nginx:1\n+    image: nginx:2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change, err := Compare(registered, tt.rendered, "app:1", "app:2")
+			if err != nil {
+				t.Fatalf("Compare() error: %v", err)
+			}
+			if tt.want == nil {
+				if change != nil {
+					t.Errorf("Compare() = %+v, want nil", change)
+				}
+				return
+			}
+			if change == nil {
+				t.Fatal("Compare() = nil, want change")
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(change.Diff, w) {
+					t.Errorf("Compare().Diff =\n%s\nwant to contain:\n%s", change.Diff, w)
+				}
+			}
+		})
+	}
+}
+
+func TestToTaskDefinition(t *testing.T) {
+	in := &ecs.RegisterTaskDefinitionInput{
+		Family:      aws.String("app"),
+		Cpu:         aws.String("256"),
+		Memory:      aws.String("512"),
+		NetworkMode: types.NetworkModeAwsvpc,
+		TaskRoleArn: aws.String("arn:aws:iam::123456789012:role/app"),
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name:  aws.String("web"),
+			Image: aws.String("nginx:1"),
+		}},
+		Volumes: []types.Volume{{Name: aws.String("data")}},
+		Tags:    []types.Tag{{Key: aws.String("team"), Value: aws.String("infra")}},
+	}
+	const tdArn = "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/app:3"
+	td := ToTaskDefinition(in, tdArn)
+	if aws.ToString(td.TaskDefinitionArn) != tdArn {
+		t.Errorf("TaskDefinitionArn = %s, want %s", aws.ToString(td.TaskDefinitionArn), tdArn)
+	}
+	// Tags are not a part of the task definition
+	want := *in
+	want.Tags = nil
+	if diff := Diff(&want, FromTaskDefinition(td, nil)); diff != "" {
+		t.Errorf("FromTaskDefinition(ToTaskDefinition()) mismatch:\n%s", diff)
+	}
+}
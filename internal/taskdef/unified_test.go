@@ -0,0 +1,57 @@
+package taskdef
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "changed line",
+			a:    "a\nb\nc\n",
+			b:    "a\nx\nc\n",
+			want: "--- from\n+++ to\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c",
+		},
+		{
+			name: "added to empty",
+			a:    "",
+			b:    "a\n",
+			want: "--- from\n+++ to\n@@ -1,0 +1,1 @@\n+a",
+		},
+		{
+			name: "context is limited",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:    "1\n2\n3\n4\n5\n6\n7\nx\n",
+			want: "--- from\n+++ to\n@@ -5,4 +5,4 @@\n 5\n 6\n 7\n-8\n+x",
+		},
+		{
+			name: "distant changes are separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+			want: "--- from\n+++ to\n" +
+				"@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n" +
+				"@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+y",
+		},
+		{
+			name: "close changes are merged",
+			a:    "1\n2\n3\n4\n5\n",
+			b:    "x\n2\n3\n4\ny\n",
+			want: "--- from\n+++ to\n@@ -1,5 +1,5 @@\n-1\n+x\n 2\n 3\n 4\n-5\n+y",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnifiedDiff(tt.a, tt.b, "from", "to"); got != tt.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package taskdef
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"gopkg.in/yaml.v3"
+)
+
+// YAML returns the task definition as YAML in the same shape as the task files.
+// Keys are in lower camel case, and empty values are omitted.
+func YAML(in *ecs.RegisterTaskDefinitionInput) (string, error) {
+	node := yamlNode(reflect.ValueOf(in))
+	if node == nil {
+		return "", nil
+	}
+	var sb strings.Builder
+	enc := yaml.NewEncoder(&sb)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return "", fmt.Errorf("failed to marshal task definition: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// yamlNode returns the YAML node of the value, or nil if the value is empty.
+func yamlNode(v reflect.Value) *yaml.Node {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return yamlNode(v.Elem())
+	case reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fv := v.Field(i)
+			if !f.IsExported() || (fv.Kind() != reflect.Pointer && fv.IsZero()) {
+				// Values which are not pointers can not be distinguished from unset
+				continue
+			}
+			value := yamlNode(fv)
+			if value == nil {
+				continue
+			}
+			if f.Name == "Name" {
+				// Name identifies the element of lists, so it is shown first
+				node.Content = append([]*yaml.Node{scalarNode("name"), value}, node.Content...)
+				continue
+			}
+			node.Content = append(node.Content, scalarNode(lowerFirst(f.Name)), value)
+		}
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return node
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil
+		}
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			value := yamlNode(v.Index(i))
+			if value == nil {
+				value = &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle}
+			}
+			node.Content = append(node.Content, value)
+		}
+		return node
+	case reflect.Map:
+		if v.Len() == 0 {
+			return nil
+		}
+		// Keys of maps, such as docker labels, are kept as they are
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, k := range keys {
+			value := yamlNode(v.MapIndex(k))
+			if value == nil {
+				value = scalarNode("")
+			}
+			node.Content = append(node.Content, scalarNode(k.String()), value)
+		}
+		return node
+	case reflect.String:
+		if v.Len() == 0 {
+			return nil
+		}
+		return scalarNode(v.String())
+	case reflect.Bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(v.Bool())}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprint(v.Int())}
+	}
+	return nil
+}
+
+func scalarNode(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
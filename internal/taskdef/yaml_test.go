@@ -0,0 +1,92 @@
+package taskdef
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+func TestYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   *ecs.RegisterTaskDefinitionInput
+		want string
+	}{
+		{
+			name: "nil",
+			in:   nil,
+			want: "",
+		},
+		{
+			name: "keys are in lower camel case and name is first",
+			in: &ecs.RegisterTaskDefinitionInput{
+				Family:      aws.String("app"),
+				NetworkMode: types.NetworkModeAwsvpc,
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Image:     aws.String("nginx"),
+					Name:      aws.String("web"),
+					Essential: aws.Bool(false),
+					Memory:    aws.Int32(512),
+				}},
+			},
+			want: `containerDefinitions:
+  - name: web
+    essential: false
+    image: nginx
+    memory: 512
+family: app
+networkMode: awsvpc
+`,
+		},
+		{
+			name: "empty values are omitted",
+			in: &ecs.RegisterTaskDefinitionInput{
+				Family:               aws.String("app"),
+				Cpu:                  aws.String(""),
+				Volumes:              []types.Volume{},
+				ContainerDefinitions: []types.ContainerDefinition{{Name: aws.String("web"), Environment: []types.KeyValuePair{}}},
+			},
+			want: `containerDefinitions:
+  - name: web
+family: app
+`,
+		},
+		{
+			name: "empty elements of lists are kept",
+			in: &ecs.RegisterTaskDefinitionInput{
+				Volumes: []types.Volume{{}, {Name: aws.String("data")}},
+			},
+			want: `volumes:
+  - {}
+  - name: data
+`,
+		},
+		{
+			name: "map keys are sorted and kept as they are",
+			in: &ecs.RegisterTaskDefinitionInput{
+				ContainerDefinitions: []types.ContainerDefinition{{
+					DockerLabels: map[string]string{"b.Label": "2", "A_LABEL": "1", "empty": ""},
+				}},
+			},
+			want: `containerDefinitions:
+  - dockerLabels:
+      A_LABEL: "1"
+      b.Label: "2"
+      empty: ""
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := YAML(tt.in)
+			if err != nil {
+				t.Fatalf("YAML() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("YAML() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// Bake is the bake time of a service after it is deployed.
+// The deployment is failed if any of Alarms enters ALARM during the bake time.
+type Bake struct {
+	Duration time.Duration
+	// Alarms are the names of the CloudWatch alarms, metric or composite
+	Alarms []string
+	// Rollback rolls the service back to PreviousTaskDefinition
+	Rollback bool
+	// PreviousTaskDefinition is the task definition ARN which is running before the deploy.
+	// If it is empty, the task definition of the latest deployment which is not PRIMARY when the watch is started is used,
+	// and the service is an error at the start if Rollback is set but the deployment is already drained.
+	PreviousTaskDefinition string
+}
+
+// checkBake checks the alarms of the deployed result during the bake time.
+// The result is changed to deploying until the bake time ends,
+// or to failed if an alarm enters ALARM, and the progress results of the bake are returned.
+func (w *Watch) checkBake(ctx context.Context, ecsService ECSAPI, c *clients, p *progress, bake Bake, result Result, now time.Time) ([]Result, Result) {
+	var results []Result
+	if p.bakeUntil.IsZero() {
+		p.bakeUntil = now.Add(bake.Duration)
+		message := fmt.Sprintf("baking for %s", bake.Duration)
+		if len(bake.Alarms) != 0 {
+			message += fmt.Sprintf(" [alarms: %s]", strings.Join(bake.Alarms, ", "))
+		}
+		result.BakeUntil = p.bakeUntil
+		results = append(results, w.progressResult(result, ProgressBake, message))
+	}
+	result.BakeUntil = p.bakeUntil
+
+	if len(bake.Alarms) != 0 {
+		cwService, err := c.cloudWatchAPI(ctx)
+		if err != nil {
+			return results, w.errorResult(result.Service, err)
+		}
+		alarms, err := describeAlarms(ctx, cwService, bake.Alarms)
+		if err != nil {
+			return results, w.errorResult(result.Service, fmt.Errorf("failed to describe alarms: %w", err))
+		}
+		for _, name := range bake.Alarms {
+			alarm, ok := alarms[name]
+			if !ok {
+				return results, w.errorResult(result.Service, fmt.Errorf("alarm %s is not found", name))
+			}
+			if p.alarms[name] != alarm.state {
+				p.alarms[name] = alarm.state
+				results = append(results, w.progressResult(result, ProgressBake, fmt.Sprintf("alarm %s: %s", name, alarm.state)))
+			}
+		}
+		for _, name := range bake.Alarms {
+			alarm := alarms[name]
+			if alarm.state != cwtypes.StateValueAlarm {
+				continue
+			}
+			result.Status = DeployFailed
+			result.Error = fmt.Errorf("alarm %s is in ALARM: %s", name, alarm.reason)
+			if bake.Rollback {
+				result = w.rollback(ctx, ecsService, p, result)
+			}
+			return results, result
+		}
+	}
+
+	if now.Before(p.bakeUntil) {
+		result.Status = Deploying
+	}
+	return results, result
+}
+
+type alarmState struct {
+	state  cwtypes.StateValue
+	reason string
+}
+
+// describeAlarms returns the states of the metric and composite alarms by name.
+func describeAlarms(ctx context.Context, cwService CloudWatchAPI, names []string) (map[string]alarmState, error) {
+	alarms := map[string]alarmState{}
+	p := cloudwatch.NewDescribeAlarmsPaginator(cwService, &cloudwatch.DescribeAlarmsInput{
+		AlarmNames: names,
+		AlarmTypes: []cwtypes.AlarmType{cwtypes.AlarmTypeMetricAlarm, cwtypes.AlarmTypeCompositeAlarm},
+	})
+	for p.HasMorePages() {
+		out, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range out.MetricAlarms {
+			alarms[aws.ToString(a.AlarmName)] = alarmState{state: a.StateValue, reason: aws.ToString(a.StateReason)}
+		}
+		for _, a := range out.CompositeAlarms {
+			alarms[aws.ToString(a.AlarmName)] = alarmState{state: a.StateValue, reason: aws.ToString(a.StateReason)}
+		}
+	}
+	return alarms, nil
+}
+
+// rollback updates the service to the previous task definition, and returns the rolled back result.
+func (w *Watch) rollback(ctx context.Context, ecsService ECSAPI, p *progress, result Result) Result {
+	_, err := ecsService.UpdateService(ctx, &ecs.UpdateServiceInput{
+		Cluster:        &w.Cluster,
+		Service:        &result.Service,
+		TaskDefinition: &p.previousTaskDefinition,
+	})
+	if err != nil {
+		result.Error = fmt.Errorf("%w, and failed to roll back: %w", result.Error, err)
+		return result
+	}
+	result.Status = RolledBack
+	result.Error = fmt.Errorf("%w, rolled back to %s", result.Error, p.previousTaskDefinition)
+	return result
+}
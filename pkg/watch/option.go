@@ -0,0 +1,204 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+
+	"github.com/kazz187/fargate-td/internal/ratelimit"
+)
+
+// ECSAPI is the ECS API which is called by the watch.
+// It is satisfied by *ecs.Client, and by watchtest.ECS in tests.
+type ECSAPI interface {
+	DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error)
+	ListTasks(ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error)
+	DescribeTasks(ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error)
+	DescribeTaskDefinition(ctx context.Context, params *ecs.DescribeTaskDefinitionInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTaskDefinitionOutput, error)
+	// UpdateService is called to roll back the service, if an alarm enters ALARM during the bake time
+	UpdateService(ctx context.Context, params *ecs.UpdateServiceInput, optFns ...func(*ecs.Options)) (*ecs.UpdateServiceOutput, error)
+}
+
+var _ ECSAPI = (*ecs.Client)(nil)
+
+// ELBV2API is the Elastic Load Balancing v2 API which is called by the watch to check the health of targets.
+// It is satisfied by *elasticloadbalancingv2.Client, and by watchtest.ELBV2 in tests.
+type ELBV2API interface {
+	DescribeTargetHealth(ctx context.Context, params *elbv2.DescribeTargetHealthInput, optFns ...func(*elbv2.Options)) (*elbv2.DescribeTargetHealthOutput, error)
+}
+
+var _ ELBV2API = (*elbv2.Client)(nil)
+
+// CloudWatchAPI is the CloudWatch API which is called by the watch to monitor alarms during the bake time.
+// It is satisfied by *cloudwatch.Client, and by watchtest.CloudWatch in tests.
+type CloudWatchAPI interface {
+	DescribeAlarms(ctx context.Context, params *cloudwatch.DescribeAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DescribeAlarmsOutput, error)
+}
+
+var _ CloudWatchAPI = (*cloudwatch.Client)(nil)
+
+// Clock is the current time and the waits between checks of the watch.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Limiter limits the rate of the API calls of the clients which are created by the watch.
+// It is satisfied by *rate.Limiter of golang.org/x/time/rate, for example.
+type Limiter interface {
+	// Wait waits until the next call is allowed, or ctx is done.
+	Wait(ctx context.Context) error
+}
+
+// options are the clients, the limiter and the clock of Watch and TaskWatch.
+type options struct {
+	ecs        ECSAPI
+	elbv2      ELBV2API
+	cloudWatch CloudWatchAPI
+	limiter    Limiter
+	clock      Clock
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		limiter: ratelimit.New(ratelimit.DefaultRate),
+		clock:   realClock{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Option configures the watch which is created by NewWatch or NewTaskWatch.
+type Option func(*options)
+
+// WithECS sets the ECS client of the watch.
+// Without it, the client is created from the default AWS config with the limiter when the watch is started.
+// With it, the other clients are not created from the default AWS config, and must be set as well if they are needed.
+func WithECS(api ECSAPI) Option {
+	return func(o *options) {
+		o.ecs = api
+	}
+}
+
+// WithELBV2 sets the Elastic Load Balancing v2 client of the watch.
+// Without it, the client is created from the default AWS config with the limiter when a service has target groups.
+func WithELBV2(api ELBV2API) Option {
+	return func(o *options) {
+		o.elbv2 = api
+	}
+}
+
+// WithCloudWatch sets the CloudWatch client of the watch.
+// Without it, the client is created from the default AWS config with the limiter when a bake time has alarms.
+func WithCloudWatch(api CloudWatchAPI) Option {
+	return func(o *options) {
+		o.cloudWatch = api
+	}
+}
+
+// WithLimiter sets the limiter of the API calls of the clients which are created by the watch,
+// which is shared by watches so that they are not throttled together (default: 10 calls per second for each watch).
+// Clients which are set by WithECS, WithELBV2 or WithCloudWatch are not limited.
+func WithLimiter(l Limiter) Option {
+	return func(o *options) {
+		o.limiter = l
+	}
+}
+
+// WithClock sets the clock of the watch (default: the system clock).
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// clients creates the clients which are not set by the options when they are needed,
+// so that APIs which are not called require neither the AWS config nor the permissions.
+type clients struct {
+	options
+	cfg *aws.Config
+	// fake is true if the ECS client is set by WithECS, then the other clients are not created from the default AWS config
+	fake bool
+}
+
+func newClients(o options) *clients {
+	return &clients{
+		options: o,
+		fake:    o.ecs != nil,
+	}
+}
+
+func (c *clients) config(ctx context.Context) (aws.Config, error) {
+	if c.cfg == nil {
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("failed to load aws config: %w", err)
+		}
+		c.cfg = &cfg
+	}
+	return *c.cfg, nil
+}
+
+func (c *clients) ecsAPI(ctx context.Context) (ECSAPI, error) {
+	if c.ecs == nil {
+		cfg, err := c.config(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.ecs = ecs.NewFromConfig(cfg, func(o *ecs.Options) {
+			o.APIOptions = append(o.APIOptions, ratelimit.Middleware(c.limiter))
+		})
+	}
+	return c.ecs, nil
+}
+
+func (c *clients) elbv2API(ctx context.Context) (ELBV2API, error) {
+	if c.elbv2 == nil {
+		if c.fake {
+			return nil, errors.New("elbv2 client is required to check target groups, set it by WithELBV2 with WithECS")
+		}
+		cfg, err := c.config(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.elbv2 = elbv2.NewFromConfig(cfg, func(o *elbv2.Options) {
+			o.APIOptions = append(o.APIOptions, ratelimit.Middleware(c.limiter))
+		})
+	}
+	return c.elbv2, nil
+}
+
+func (c *clients) cloudWatchAPI(ctx context.Context) (CloudWatchAPI, error) {
+	if c.cloudWatch == nil {
+		if c.fake {
+			return nil, errors.New("cloudwatch client is required to check alarms, set it by WithCloudWatch with WithECS")
+		}
+		cfg, err := c.config(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.cloudWatch = cloudwatch.NewFromConfig(cfg, func(o *cloudwatch.Options) {
+			o.APIOptions = append(o.APIOptions, ratelimit.Middleware(c.limiter))
+		})
+	}
+	return c.cloudWatch, nil
+}
@@ -0,0 +1,104 @@
+package watch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// progress is the progress of a service which is already emitted.
+type progress struct {
+	// since is the time when the watch is started, older events are not emitted
+	since   time.Time
+	seen    map[string]bool
+	counted bool
+	running int32
+	pending int32
+	desired int32
+	failed  int32
+	// targets are the last messages of the target health by target group ARN
+	targets map[string]string
+	// bakeUntil is the end of the bake time, which is set when the deployment is deployed
+	bakeUntil time.Time
+	// alarms are the last states of the alarms by name
+	alarms map[string]cwtypes.StateValue
+	// previousTaskDefinition is the task definition to roll back to, which is running before the deploy
+	previousTaskDefinition string
+}
+
+func newProgress(since time.Time) *progress {
+	return &progress{
+		since:   since,
+		seen:    map[string]bool{},
+		targets: map[string]string{},
+		alarms:  map[string]cwtypes.StateValue{},
+	}
+}
+
+// progress returns the progress results of the service events and the task counts of the deployment since the last call.
+func (w *Watch) progress(p *progress, service types.Service, deploymentID string) []Result {
+	serviceName := aws.ToString(service.ServiceName)
+	var results []Result
+	// Events are sorted from the newest
+	for i := len(service.Events) - 1; i >= 0; i-- {
+		e := service.Events[i]
+		id := aws.ToString(e.Id)
+		if p.seen[id] || e.CreatedAt == nil || e.CreatedAt.Before(p.since) {
+			continue
+		}
+		p.seen[id] = true
+		results = append(results, Result{
+			Cluster:      w.Cluster,
+			Service:      serviceName,
+			Status:       Progress,
+			DeploymentID: deploymentID,
+			Time:         *e.CreatedAt,
+			Message:      aws.ToString(e.Message),
+			ProgressKind: ProgressEvent,
+		})
+	}
+
+	for _, d := range service.Deployments {
+		if aws.ToString(d.Id) != deploymentID {
+			continue
+		}
+		if p.counted && d.RunningCount == p.running && d.PendingCount == p.pending && d.DesiredCount == p.desired && d.FailedTasks == p.failed {
+			break
+		}
+		p.counted = true
+		p.running, p.pending, p.desired, p.failed = d.RunningCount, d.PendingCount, d.DesiredCount, d.FailedTasks
+		message := fmt.Sprintf("running: %d, pending: %d, desired: %d", d.RunningCount, d.PendingCount, d.DesiredCount)
+		if d.FailedTasks != 0 {
+			message += fmt.Sprintf(", failed: %d", d.FailedTasks)
+		}
+		results = append(results, Result{
+			Cluster:        w.Cluster,
+			Service:        serviceName,
+			Status:         Progress,
+			DeploymentID:   deploymentID,
+			TaskDefinition: aws.ToString(d.TaskDefinition),
+			RolloutState:   d.RolloutState,
+			DesiredCount:   d.DesiredCount,
+			RunningCount:   d.RunningCount,
+			PendingCount:   d.PendingCount,
+			FailedTasks:    d.FailedTasks,
+			Time:           w.clock.Now(),
+			Message:        message,
+			ProgressKind:   ProgressCounts,
+		})
+	}
+	return results
+}
+
+// progressResult returns the progress result of the message, with the status of the deployment in the result.
+func (w *Watch) progressResult(result Result, kind int, message string) Result {
+	result.Status = Progress
+	result.Error = nil
+	result.Time = w.clock.Now()
+	result.Message = message
+	result.ProgressKind = kind
+	return result
+}
@@ -0,0 +1,128 @@
+package watch
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// maxDescribeTasks is the maximum number of tasks of DescribeTasks.
+const maxDescribeTasks = 100
+
+// maxStoppedTasks is the maximum number of stopped tasks in a result, the latest ones are kept.
+const maxStoppedTasks = 3
+
+// StoppedTask is a task of the deployment which is stopped, to diagnose the failure of the deployment.
+type StoppedTask struct {
+	TaskArn           string
+	TaskDefinitionArn string
+	StopCode          types.TaskStopCode
+	StoppedReason     string
+	StoppedAt         *time.Time
+	Containers        []StoppedContainer
+}
+
+// StoppedContainer is a container of a stopped task.
+type StoppedContainer struct {
+	Name     string
+	ExitCode *int32
+	Reason   string
+	// Essential reports whether the task is stopped by the container, it is true unless the container is known to be non-essential
+	Essential bool
+}
+
+// Failed reports whether the container exited with non-zero code or failed to run.
+func (c StoppedContainer) Failed() bool {
+	if c.ExitCode != nil {
+		return *c.ExitCode != 0
+	}
+	return c.Reason != ""
+}
+
+// stoppedTasks returns the latest stopped tasks which are started by the deployment.
+func (w *Watch) stoppedTasks(ctx context.Context, ecsService ECSAPI, deploymentID string) ([]StoppedTask, error) {
+	tasks, err := w.deploymentTasks(ctx, ecsService, deploymentID, types.DesiredStatusStopped)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return aws.ToTime(tasks[i].StoppedAt).After(aws.ToTime(tasks[j].StoppedAt))
+	})
+	if len(tasks) > maxStoppedTasks {
+		tasks = tasks[:maxStoppedTasks]
+	}
+
+	stopped := make([]StoppedTask, 0, len(tasks))
+	for _, t := range tasks {
+		stopped = append(stopped, newStoppedTask(t))
+	}
+	return stopped, nil
+}
+
+func newStoppedTask(t types.Task) StoppedTask {
+	st := StoppedTask{
+		TaskArn:           aws.ToString(t.TaskArn),
+		TaskDefinitionArn: aws.ToString(t.TaskDefinitionArn),
+		StopCode:          t.StopCode,
+		StoppedReason:     aws.ToString(t.StoppedReason),
+		StoppedAt:         t.StoppedAt,
+	}
+	for _, c := range t.Containers {
+		st.Containers = append(st.Containers, StoppedContainer{
+			Name:      aws.ToString(c.Name),
+			ExitCode:  c.ExitCode,
+			Reason:    aws.ToString(c.Reason),
+			Essential: true,
+		})
+	}
+	return st
+}
+
+// deploymentTasks returns the tasks of the desired status which are started by the deployment.
+func (w *Watch) deploymentTasks(ctx context.Context, ecsService ECSAPI, deploymentID string, desiredStatus types.DesiredStatus) ([]types.Task, error) {
+	// Tasks of a service are started by the ID of the deployment, such as "ecs-svc/1234567890123456789"
+	taskArns, err := listTasks(ctx, ecsService, &ecs.ListTasksInput{
+		Cluster:       &w.Cluster,
+		StartedBy:     &deploymentID,
+		DesiredStatus: desiredStatus,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return describeTasks(ctx, ecsService, w.Cluster, taskArns)
+}
+
+// listTasks returns the ARNs of all pages of the tasks.
+func listTasks(ctx context.Context, ecsService ECSAPI, in *ecs.ListTasksInput) ([]string, error) {
+	var taskArns []string
+	p := ecs.NewListTasksPaginator(ecsService, in)
+	for p.HasMorePages() {
+		out, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		taskArns = append(taskArns, out.TaskArns...)
+	}
+	return taskArns, nil
+}
+
+// describeTasks describes the tasks in chunks. Tasks which are not found are skipped.
+func describeTasks(ctx context.Context, ecsService ECSAPI, cluster string, taskArns []string) ([]types.Task, error) {
+	var tasks []types.Task
+	for i := 0; i < len(taskArns); i += maxDescribeTasks {
+		end := min(i+maxDescribeTasks, len(taskArns))
+		out, err := ecsService.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: &cluster,
+			Tasks:   taskArns[i:end],
+		})
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, out.Tasks...)
+	}
+	return tasks, nil
+}
@@ -0,0 +1,125 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+// targetHealth is the health of the targets of a target group.
+type targetHealth struct {
+	targetGroupArn string
+	// expected is the number of the running tasks of the deployment
+	expected int
+	healthy  int
+	// states are the number of the new targets which are not healthy, by state
+	states map[elbtypes.TargetHealthStateEnum]int
+	// draining is the number of the targets of the other tasks which are draining
+	draining int
+}
+
+// Healthy reports whether all running tasks of the deployment are healthy targets.
+func (th targetHealth) Healthy() bool {
+	return th.healthy == th.expected
+}
+
+func (th targetHealth) String() string {
+	// The name of the target group is in the ARN, such as "arn:aws:elasticloadbalancing:...:targetgroup/web/0123456789abcdef"
+	name := th.targetGroupArn
+	if i := strings.Index(name, "targetgroup/"); i >= 0 {
+		name = strings.SplitN(name[i+len("targetgroup/"):], "/", 2)[0]
+	}
+	message := fmt.Sprintf("target group %s: healthy: %d/%d", name, th.healthy, th.expected)
+	states := make([]string, 0, len(th.states))
+	for state := range th.states {
+		states = append(states, string(state))
+	}
+	sort.Strings(states)
+	for _, state := range states {
+		message += fmt.Sprintf(", %s: %d", state, th.states[elbtypes.TargetHealthStateEnum(state)])
+	}
+	if th.draining != 0 {
+		message += fmt.Sprintf(", old draining: %d", th.draining)
+	}
+	return message
+}
+
+// checkTargets checks the health of the targets of the deployment in the target groups of the service.
+// The deployed result is changed to deploying until all running tasks of the deployment are healthy targets,
+// and the progress results are returned when the health of a target group is changed.
+// Only IP targets are supported, which are used by Fargate tasks.
+func (w *Watch) checkTargets(ctx context.Context, ecsService ECSAPI, elbService ELBV2API, p *progress, service types.Service, result Result) ([]Result, Result) {
+	tasks, err := w.deploymentTasks(ctx, ecsService, result.DeploymentID, types.DesiredStatusRunning)
+	if err != nil {
+		return nil, w.errorResult(result.Service, fmt.Errorf("failed to list tasks of deployment: %w", err))
+	}
+	ips := map[string]bool{}
+	for _, t := range tasks {
+		if aws.ToString(t.LastStatus) != string(types.DesiredStatusRunning) {
+			continue
+		}
+		for _, c := range t.Containers {
+			for _, ni := range c.NetworkInterfaces {
+				if ni.PrivateIpv4Address != nil {
+					ips[*ni.PrivateIpv4Address] = true
+				}
+			}
+		}
+	}
+
+	var results []Result
+	healthy := true
+	for _, lb := range service.LoadBalancers {
+		if lb.TargetGroupArn == nil {
+			// Classic load balancers are not supported
+			continue
+		}
+		out, err := elbService.DescribeTargetHealth(ctx, &elbv2.DescribeTargetHealthInput{
+			TargetGroupArn: lb.TargetGroupArn,
+		})
+		if err != nil {
+			return nil, w.errorResult(result.Service, fmt.Errorf("failed to describe target health: %w", err))
+		}
+		th := targetHealth{
+			targetGroupArn: *lb.TargetGroupArn,
+			expected:       len(ips),
+			states:         map[elbtypes.TargetHealthStateEnum]int{},
+		}
+		for _, d := range out.TargetHealthDescriptions {
+			if d.Target == nil || d.TargetHealth == nil {
+				continue
+			}
+			state := d.TargetHealth.State
+			if !ips[aws.ToString(d.Target.Id)] || (lb.ContainerPort != nil && aws.ToInt32(d.Target.Port) != *lb.ContainerPort) {
+				if state == elbtypes.TargetHealthStateEnumDraining {
+					th.draining++
+				}
+				continue
+			}
+			if state == elbtypes.TargetHealthStateEnumHealthy {
+				th.healthy++
+			} else {
+				th.states[state]++
+			}
+		}
+		if !th.Healthy() {
+			healthy = false
+		}
+		message := th.String()
+		if p.targets[th.targetGroupArn] == message {
+			continue
+		}
+		p.targets[th.targetGroupArn] = message
+		results = append(results, w.progressResult(result, ProgressTargets, message))
+	}
+	if result.Status == Deployed && !healthy {
+		result.Status = Deploying
+	}
+	return results, result
+}
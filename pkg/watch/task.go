@@ -0,0 +1,330 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/sirupsen/logrus"
+)
+
+const taskStatusStopped = "STOPPED"
+
+// TaskWatch watches standalone tasks until they are stopped, such as the tasks started by RunTask or launched by a cron job.
+type TaskWatch struct {
+	Cluster string
+	// TaskArns are the tasks to be watched
+	TaskArns []string
+	// Launch finds the tasks to be watched which are launched by a schedule, in addition to TaskArns
+	Launch *Launch
+	// Timeout is the time from the start of the watch, or from Launch.After if it is later, 0 means no timeout
+	Interval, Timeout time.Duration
+	// Results are closed when all tasks are stopped, timed out or canceled
+	Results chan TaskResult
+
+	options
+}
+
+// Launch is the condition of the tasks which are launched by a schedule.
+type Launch struct {
+	// Family is the task definition family of the tasks
+	Family string
+	// Group is the task group of the tasks (default: "family:" + Family)
+	Group string
+	// After is the time when the tasks are launched, the watch waits for it
+	After time.Time
+	// Count is the number of the tasks to be launched (default: 1)
+	Count int
+}
+
+// TaskResult is the status of a standalone task.
+type TaskResult struct {
+	Cluster string
+	TaskArn string
+	// Status is TaskSucceeded, TaskFailed, Error, Timeout, Canceled or Progress
+	Status     int
+	Error      error
+	LastStatus string
+	// Stopped is the stopped task with the exit codes of the containers and whether they are essential, if the task is stopped
+	Stopped *StoppedTask
+	// Time and Message are the time and the message of the progress
+	Time    time.Time
+	Message string
+}
+
+func NewTaskWatch(cluster string, taskArns []string, interval, timeout time.Duration, opts ...Option) *TaskWatch {
+	return &TaskWatch{
+		Cluster:  cluster,
+		TaskArns: taskArns,
+		Interval: interval,
+		Timeout:  timeout,
+		Results:  make(chan TaskResult, len(taskArns)+1),
+		options:  newOptions(opts),
+	}
+}
+
+// Start watches the tasks until they are stopped, timed out or ctx is canceled.
+func (w *TaskWatch) Start(ctx context.Context) {
+	defer close(w.Results)
+	ecsService, err := newClients(w.options).ecsAPI(ctx)
+	if err != nil {
+		w.Results <- TaskResult{
+			Cluster: w.Cluster,
+			Status:  Error,
+			Error:   err,
+		}
+		return
+	}
+
+	// lastStatuses are the last statuses of the tasks which are not stopped, by task ARN
+	lastStatuses := map[string]string{}
+	for _, arn := range w.TaskArns {
+		lastStatuses[arn] = ""
+	}
+	start := w.clock.Now()
+	if w.Launch != nil && w.Launch.After.After(start) {
+		// Tasks are not launched until the time
+		select {
+		case <-w.clock.After(w.Launch.After.Sub(start)):
+		case <-ctx.Done():
+			w.cancel(ctx, lastStatuses, true)
+			return
+		}
+		start = w.Launch.After
+	}
+	deadline := start.Add(w.Timeout)
+	l := newLauncher(w.Launch)
+	essentials := map[string]map[string]bool{}
+	for {
+		now := w.clock.Now()
+		if !l.done() {
+			arns, err := l.find(ctx, ecsService, w.Cluster)
+			if ctx.Err() != nil {
+				w.cancel(ctx, lastStatuses, true)
+				return
+			}
+			if err != nil {
+				w.Results <- TaskResult{Cluster: w.Cluster, Status: Error, Error: fmt.Errorf("failed to find launched tasks: %w", err)}
+				return
+			}
+			for _, arn := range arns {
+				lastStatuses[arn] = ""
+				w.Results <- w.progressResult(TaskResult{TaskArn: arn}, "task is launched")
+			}
+		}
+
+		taskArns := make([]string, 0, len(lastStatuses))
+		for arn := range lastStatuses {
+			taskArns = append(taskArns, arn)
+		}
+		sort.Strings(taskArns)
+		tasks, err := describeTasks(ctx, ecsService, w.Cluster, taskArns)
+		if ctx.Err() != nil {
+			w.cancel(ctx, lastStatuses, !l.done())
+			return
+		}
+		if err != nil {
+			for _, arn := range taskArns {
+				w.Results <- TaskResult{Cluster: w.Cluster, TaskArn: arn, Status: Error, Error: fmt.Errorf("failed to describe task: %w", err)}
+			}
+			return
+		}
+		found := map[string]bool{}
+		for _, t := range tasks {
+			arn := aws.ToString(t.TaskArn)
+			found[arn] = true
+			status := aws.ToString(t.LastStatus)
+			if status != lastStatuses[arn] {
+				lastStatuses[arn] = status
+				w.Results <- w.progressResult(TaskResult{TaskArn: arn, LastStatus: status}, "status: "+status)
+			}
+			if status != taskStatusStopped {
+				continue
+			}
+			w.Results <- w.stopped(ctx, ecsService, essentials, t)
+			delete(lastStatuses, arn)
+		}
+		for _, arn := range taskArns {
+			if !found[arn] {
+				w.Results <- TaskResult{Cluster: w.Cluster, TaskArn: arn, Status: Error, Error: errors.New("task is not found")}
+				delete(lastStatuses, arn)
+			}
+		}
+
+		if len(lastStatuses) == 0 && l.done() {
+			return
+		}
+		wait := w.Interval
+		if w.Timeout > 0 {
+			if !now.Before(deadline) {
+				w.timeout(lastStatuses, taskArns, l)
+				return
+			}
+			wait = min(wait, deadline.Sub(now))
+		}
+		select {
+		case <-w.clock.After(wait):
+		case <-ctx.Done():
+			w.cancel(ctx, lastStatuses, !l.done())
+			return
+		}
+	}
+}
+
+// timeout sends the results of the tasks which are not stopped, and of the launch if it is not done, with Timeout status.
+func (w *TaskWatch) timeout(lastStatuses map[string]string, taskArns []string, l *launcher) {
+	for _, arn := range taskArns {
+		if status, ok := lastStatuses[arn]; ok {
+			logrus.Errorf("timeout [cluster: %s, task: %s]", w.Cluster, arn)
+			w.Results <- TaskResult{Cluster: w.Cluster, TaskArn: arn, Status: Timeout, LastStatus: status, Error: fmt.Errorf("task is %s", status)}
+		}
+	}
+	if !l.done() {
+		w.Results <- TaskResult{Cluster: w.Cluster, Status: Timeout, Error: fmt.Errorf("%d of %d tasks are not launched", l.count-len(l.launched), l.count)}
+	}
+}
+
+func (w *TaskWatch) progressResult(result TaskResult, message string) TaskResult {
+	result.Cluster = w.Cluster
+	result.Status = Progress
+	result.Time = w.clock.Now()
+	result.Message = message
+	return result
+}
+
+// cancel sends the results of the tasks which are not stopped, and of the launch if it is waited, with Canceled status.
+func (w *TaskWatch) cancel(ctx context.Context, lastStatuses map[string]string, launching bool) {
+	taskArns := make([]string, 0, len(lastStatuses))
+	for arn := range lastStatuses {
+		taskArns = append(taskArns, arn)
+	}
+	sort.Strings(taskArns)
+	for _, arn := range taskArns {
+		w.Results <- TaskResult{Cluster: w.Cluster, TaskArn: arn, Status: Canceled, LastStatus: lastStatuses[arn], Error: ctx.Err()}
+	}
+	if launching {
+		w.Results <- TaskResult{Cluster: w.Cluster, Status: Canceled, Error: ctx.Err()}
+	}
+}
+
+// stopped returns the result of the stopped task by the exit codes of the essential containers.
+func (w *TaskWatch) stopped(ctx context.Context, ecsService ECSAPI, essentials map[string]map[string]bool, t types.Task) TaskResult {
+	st := newStoppedTask(t)
+	result := TaskResult{
+		Cluster:    w.Cluster,
+		TaskArn:    st.TaskArn,
+		Status:     TaskSucceeded,
+		LastStatus: taskStatusStopped,
+		Stopped:    &st,
+	}
+	essential, ok := essentials[st.TaskDefinitionArn]
+	if !ok {
+		out, err := ecsService.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+			TaskDefinition: &st.TaskDefinitionArn,
+		})
+		if err != nil {
+			// All containers are regarded as essential
+			logrus.Warnf("failed to describe task definition %s: %s", st.TaskDefinitionArn, err)
+		} else {
+			essential = map[string]bool{}
+			for _, c := range out.TaskDefinition.ContainerDefinitions {
+				essential[aws.ToString(c.Name)] = c.Essential == nil || *c.Essential
+			}
+			essentials[st.TaskDefinitionArn] = essential
+		}
+	}
+	for i, c := range st.Containers {
+		if essential != nil {
+			st.Containers[i].Essential = essential[c.Name]
+		}
+	}
+	for _, c := range st.Containers {
+		if !c.Essential {
+			continue
+		}
+		if c.ExitCode == nil {
+			result.Status = TaskFailed
+			result.Error = fmt.Errorf("container %s stopped without exit code: %s (%s)", c.Name, st.StoppedReason, c.Reason)
+			break
+		}
+		if *c.ExitCode != 0 {
+			result.Status = TaskFailed
+			result.Error = fmt.Errorf("container %s exited with code %d", c.Name, *c.ExitCode)
+			break
+		}
+	}
+	return result
+}
+
+// launcher finds the tasks which are launched by the condition.
+type launcher struct {
+	launch   *Launch
+	count    int
+	launched map[string]bool
+	// skipped are the tasks which do not match the condition
+	skipped map[string]bool
+}
+
+func newLauncher(launch *Launch) *launcher {
+	l := &launcher{
+		launch:   launch,
+		launched: map[string]bool{},
+		skipped:  map[string]bool{},
+	}
+	if launch != nil {
+		l.count = max(launch.Count, 1)
+	}
+	return l
+}
+
+func (l *launcher) done() bool {
+	return len(l.launched) >= l.count
+}
+
+// find returns the tasks which are newly launched, in the order of creation.
+func (l *launcher) find(ctx context.Context, ecsService ECSAPI, cluster string) ([]string, error) {
+	group := l.launch.Group
+	if group == "" {
+		group = "family:" + l.launch.Family
+	}
+	var taskArns []string
+	// Tasks which are already stopped are also listed, since short tasks may stop between checks
+	for _, desiredStatus := range []types.DesiredStatus{types.DesiredStatusRunning, types.DesiredStatusStopped} {
+		arns, err := listTasks(ctx, ecsService, &ecs.ListTasksInput{
+			Cluster:       &cluster,
+			Family:        &l.launch.Family,
+			DesiredStatus: desiredStatus,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, arn := range arns {
+			if !l.launched[arn] && !l.skipped[arn] {
+				taskArns = append(taskArns, arn)
+			}
+		}
+	}
+	tasks, err := describeTasks(ctx, ecsService, cluster, taskArns)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return aws.ToTime(tasks[i].CreatedAt).Before(aws.ToTime(tasks[j].CreatedAt))
+	})
+	var launched []string
+	for _, t := range tasks {
+		arn := aws.ToString(t.TaskArn)
+		if aws.ToString(t.Group) != group || aws.ToTime(t.CreatedAt).Before(l.launch.After) || l.done() {
+			l.skipped[arn] = true
+			continue
+		}
+		l.launched[arn] = true
+		launched = append(launched, arn)
+	}
+	return launched, nil
+}
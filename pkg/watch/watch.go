@@ -4,15 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"sync"
+	"sort"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/sirupsen/logrus"
-
-	"github.com/kazz187/fargate-td/internal/util"
 )
 
 const (
@@ -21,185 +19,399 @@ const (
 	DeployFailed
 	Error
 	Timeout
+	// RolledBack is the status of the deployment which is failed and rolled back by the deployment circuit breaker
+	RolledBack
+	// Progress is not the status of the deployment but a service event or a change of the task counts of the deployment.
+	// It is followed by other results of the service.
+	Progress
+	// Canceled is the status of the deployment which is still in progress when the context of the watch is canceled
+	Canceled
+	// TaskSucceeded is the status of the standalone task whose essential containers exited with code 0
+	TaskSucceeded
+	// TaskFailed is the status of the standalone task whose essential container exited with non-zero code or failed to run
+	TaskFailed
+	// Superseded is the status of the deployment which is replaced by another deployment which is not a rollback, such as a newer deploy
+	Superseded
 )
 
-const statusRunning = "RUNNING"
-const statusDeactivating = "DEACTIVATING"
-const statusStopping = "STOPPING"
-const statusDeprovisioning = "DEPROVISIONING"
-const statusStopped = "STOPPED"
-
-var failedStatuses = []string{
-	statusDeactivating,
-	statusStopping,
-	statusDeprovisioning,
-	statusStopped,
-}
+// Kinds of the progress results
+const (
+	// ProgressEvent is a service event
+	ProgressEvent = iota
+	// ProgressCounts is a change of the task counts of the deployment
+	ProgressCounts
+	// ProgressTargets is a change of the health of the targets of a target group
+	ProgressTargets
+	// ProgressBake is the start of the bake time or a change of the state of an alarm
+	ProgressBake
+)
+
+const deploymentStatusPrimary = "PRIMARY"
+
+// maxDescribeServices is the maximum number of services of DescribeServices.
+const maxDescribeServices = 10
 
 type Watch struct {
 	Cluster           string
 	Services          []string
 	Interval, Timeout time.Duration
-	Results           chan Result
+	// Timeouts are the timeouts by service name, which override Timeout
+	Timeouts map[string]time.Duration
+	// Bakes are the bake times by service name, the services are deployed after them
+	Bakes map[string]Bake
+	// Results are closed when all services are finished, timed out or canceled
+	Results chan Result
+
+	options
 }
 
+// Result is the status of the deployment which is PRIMARY when the watch is started.
 type Result struct {
-	Cluster string
-	Service string
-	Status  int
-	Error   error
+	Cluster      string
+	Service      string
+	Status       int
+	Error        error
+	DeploymentID string
+	// TaskDefinition is the task definition ARN of the deployment
+	TaskDefinition string
+	RolloutState   types.DeploymentRolloutState
+	// Reason is the rolloutStateReason of the deployment
+	Reason       string
+	DesiredCount int32
+	RunningCount int32
+	PendingCount int32
+	FailedTasks  int32
+	// Time, Message and ProgressKind are the time, the message and the kind of the progress
+	Time         time.Time
+	Message      string
+	ProgressKind int
+	// StoppedTasks are the latest stopped tasks of the deployment, if it is not deployed
+	StoppedTasks []StoppedTask
+	// BakeUntil is the end of the bake time, if it is started
+	BakeUntil time.Time
 }
 
-func NewWatch(cluster string, services []string, interval, timeout time.Duration) *Watch {
+func NewWatch(cluster string, services []string, interval, timeout time.Duration, opts ...Option) *Watch {
 	return &Watch{
 		Cluster:  cluster,
 		Services: services,
 		Interval: interval,
 		Timeout:  timeout,
 		Results:  make(chan Result, len(services)),
+		options:  newOptions(opts),
 	}
 }
 
-func (w *Watch) Start() {
-	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(context.Background())
+// Start watches the services until they are finished, timed out or ctx is canceled.
+func (w *Watch) Start(ctx context.Context) {
+	defer close(w.Results)
+	c := newClients(w.options)
+	ecsService, err := c.ecsAPI(ctx)
 	if err != nil {
 		w.Results <- Result{
 			Status: Error,
-			Error:  fmt.Errorf("failed to load aws config: %w", err),
+			Error:  err,
 		}
-		close(w.Results)
 		return
 	}
-
-	ecsService := ecs.NewFromConfig(cfg)
-	descServicesIn := &ecs.DescribeServicesInput{
-		Cluster:  &w.Cluster,
-		Include:  nil,
-		Services: w.Services,
+	services, err := w.describeServices(ctx, ecsService, w.Services)
+	if ctx.Err() != nil {
+		w.cancel(ctx, w.Services, nil)
+		return
 	}
-	descServices, err := ecsService.DescribeServices(ctx, descServicesIn)
 	if err != nil {
 		w.Results <- Result{
 			Status: Error,
 			Error:  fmt.Errorf("failed to describe services: %w", err),
 		}
-		close(w.Results)
 		return
 	}
 
-	wg := sync.WaitGroup{}
-	for _, service := range descServices.Services {
-		wg.Add(1)
-		go w.ticker(ctx, &wg, service, ecsService)
-	}
-	wg.Wait()
-	close(w.Results)
-}
-
-func (w *Watch) check(ctx context.Context, ecsService *ecs.Client, service types.Service) Result {
-	listTasksIn := &ecs.ListTasksInput{
-		Cluster:     service.ClusterArn,
-		ServiceName: service.ServiceName,
-	}
-	listTasks, err := ecsService.ListTasks(ctx, listTasksIn)
-	if err != nil {
-		return Result{
-			Cluster: w.Cluster,
-			Service: *service.ServiceName,
-			Status:  Error,
-			Error:   err,
+	// deploymentIDs are the IDs of the deployments which are not finished, by service name
+	deploymentIDs := map[string]string{}
+	progresses := map[string]*progress{}
+	deadlines := map[string]time.Time{}
+	start := w.clock.Now()
+	for _, name := range w.Services {
+		service, ok := services[name]
+		if !ok {
+			w.Results <- w.errorResult(name, errors.New("service is not found"))
+			continue
+		}
+		deployment := primaryDeployment(service)
+		if deployment == nil {
+			w.Results <- w.errorResult(name, errors.New("primary deployment is not found"))
+			continue
+		}
+		previous := w.Bakes[name].PreviousTaskDefinition
+		if previous == "" {
+			previous = previousTaskDefinition(service)
+		}
+		if w.Bakes[name].Rollback && previous == "" {
+			// Fail before the bake time, rather than when an alarm fires
+			w.Results <- w.errorResult(name, errors.New("task definition to roll back to is not found since the previous deployment is drained, pass the task definition before the deploy"))
+			continue
 		}
+		deploymentIDs[name] = aws.ToString(deployment.Id)
+		progresses[name] = newProgress(start)
+		progresses[name].previousTaskDefinition = previous
+		deadlines[name] = start.Add(w.timeout(name))
 	}
 
-	descTasksIn := &ecs.DescribeTasksInput{
-		Cluster: service.ClusterArn,
-		Tasks:   listTasks.TaskArns,
-	}
-	tasks, err := ecsService.DescribeTasks(ctx, descTasksIn)
-	if err != nil {
-		return Result{
-			Cluster: w.Cluster,
-			Service: *service.ServiceName,
-			Status:  Error,
-			Error:   err,
-		}
-	}
-	runningCount := int64(0)
-	for _, task := range tasks.Tasks {
-		logrus.Debugln("Service: " + *service.TaskDefinition)
-		logrus.Debugln("Task: " + *task.TaskDefinitionArn)
-		logrus.Debugln("DesiredStatus: " + *task.DesiredStatus)
-		logrus.Debugln("LastStatus: " + *task.LastStatus)
-		if *task.TaskDefinitionArn != *service.TaskDefinition {
-			continue
+	last := map[string]Result{}
+	for len(deploymentIDs) != 0 {
+		names := sortedNames(deploymentIDs)
+		// Services are described together in chunks, rather than one by one
+		services, err := w.describeServices(ctx, ecsService, names)
+		if ctx.Err() != nil {
+			w.cancel(ctx, names, last)
+			return
 		}
-		if util.ContainsString(failedStatuses, *task.LastStatus) {
-			return Result{
-				Cluster: w.Cluster,
-				Service: *service.ServiceName,
-				Status:  DeployFailed,
-				Error:   errors.New("task status is " + *task.LastStatus),
+		now := w.clock.Now()
+		for _, name := range names {
+			result := w.errorResult(name, fmt.Errorf("failed to describe service: %w", err))
+			if err == nil {
+				service, ok := services[name]
+				if !ok {
+					result = w.errorResult(name, errors.New("service is not found"))
+				} else {
+					for _, p := range w.progress(progresses[name], service, deploymentIDs[name]) {
+						w.Results <- p
+					}
+					result = w.check(service, deploymentIDs[name], progresses[name].previousTaskDefinition)
+					if len(service.LoadBalancers) != 0 && (result.Status == Deploying || result.Status == Deployed) {
+						if elbService, err := c.elbv2API(ctx); err != nil {
+							result = w.errorResult(name, err)
+						} else {
+							var targets []Result
+							targets, result = w.checkTargets(ctx, ecsService, elbService, progresses[name], service, result)
+							for _, p := range targets {
+								w.Results <- p
+							}
+						}
+					}
+					if bake := w.Bakes[name]; bake.Duration > 0 && result.Status == Deployed {
+						var baking []Result
+						baking, result = w.checkBake(ctx, ecsService, c, progresses[name], bake, result, now)
+						for _, p := range baking {
+							w.Results <- p
+						}
+					}
+				}
+			}
+			// The bake time is not limited by the timeout, even while the targets are not healthy during it
+			baking := now.Before(progresses[name].bakeUntil)
+			if result.Status == Deploying {
+				result.BakeUntil = progresses[name].bakeUntil
+			}
+			last[name] = result
+			if result.Status == Deploying && !baking && !now.Before(deadlines[name]) {
+				logrus.Errorf("timeout [cluster: %s, service: %s]", w.Cluster, name)
+				result.Status = Timeout
+			}
+			if result.Status != Deploying {
+				w.Results <- w.diagnose(ctx, ecsService, result)
+				delete(deploymentIDs, name)
+			}
+		}
+		if len(deploymentIDs) == 0 {
+			return
+		}
+
+		// The services are checked again after the interval, or at the nearest deadline or end of the bake time
+		next := now.Add(w.Interval)
+		for name := range deploymentIDs {
+			for _, t := range []time.Time{deadlines[name], progresses[name].bakeUntil} {
+				if t.After(now) && t.Before(next) {
+					next = t
+				}
 			}
 		}
-		if *task.DesiredStatus != statusRunning || *task.LastStatus != statusRunning {
-			return Result{
+		select {
+		case <-w.clock.After(next.Sub(now)):
+		case <-ctx.Done():
+			w.cancel(ctx, sortedNames(deploymentIDs), last)
+			return
+		}
+	}
+}
+
+func sortedNames(deploymentIDs map[string]string) []string {
+	names := make([]string, 0, len(deploymentIDs))
+	for name := range deploymentIDs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// timeout returns the timeout of the service.
+func (w *Watch) timeout(serviceName string) time.Duration {
+	if t, ok := w.Timeouts[serviceName]; ok && t > 0 {
+		return t
+	}
+	return w.Timeout
+}
+
+// cancel sends the last results of the services with Canceled status.
+func (w *Watch) cancel(ctx context.Context, names []string, last map[string]Result) {
+	for _, name := range names {
+		result, ok := last[name]
+		if !ok {
+			result = Result{
 				Cluster: w.Cluster,
-				Service: *service.ServiceName,
-				Status:  Deploying,
-				Error:   nil,
+				Service: name,
 			}
 		}
-		runningCount++
+		result.Status = Canceled
+		result.Error = ctx.Err()
+		w.Results <- result
+	}
+}
+
+// diagnose adds the stopped tasks of the deployment to the result, if it is not deployed.
+func (w *Watch) diagnose(ctx context.Context, ecsService ECSAPI, result Result) Result {
+	switch result.Status {
+	case DeployFailed, RolledBack, Timeout:
+	default:
+		return result
+	}
+	if result.DeploymentID == "" {
+		return result
 	}
-	if int32(runningCount) != service.DesiredCount {
-		return Result{
-			Cluster: w.Cluster,
-			Service: *service.ServiceName,
-			Status:  Deploying,
-			Error:   nil,
+	tasks, err := w.stoppedTasks(ctx, ecsService, result.DeploymentID)
+	if err != nil {
+		logrus.Warnf("failed to get stopped tasks [cluster: %s, service: %s]: %s", result.Cluster, result.Service, err)
+		return result
+	}
+	result.StoppedTasks = tasks
+	return result
+}
+
+// describeServices describes the services in chunks, and returns them by name.
+func (w *Watch) describeServices(ctx context.Context, ecsService ECSAPI, names []string) (map[string]types.Service, error) {
+	services := map[string]types.Service{}
+	for i := 0; i < len(names); i += maxDescribeServices {
+		end := min(i+maxDescribeServices, len(names))
+		res, err := ecsService.DescribeServices(ctx, &ecs.DescribeServicesInput{
+			Cluster:  &w.Cluster,
+			Services: names[i:end],
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range res.Services {
+			services[aws.ToString(service.ServiceName)] = service
 		}
 	}
+	return services, nil
+}
+
+func (w *Watch) errorResult(serviceName string, err error) Result {
 	return Result{
 		Cluster: w.Cluster,
-		Service: *service.ServiceName,
-		Status:  Deployed,
-		Error:   nil,
+		Service: serviceName,
+		Status:  Error,
+		Error:   err,
 	}
-
 }
 
-func (w *Watch) ticker(ctx context.Context, wg *sync.WaitGroup, service types.Service, ecsService *ecs.Client) {
-	defer wg.Done()
-	ticker := time.NewTicker(w.Interval)
-	timer := time.NewTimer(w.Timeout)
-	defer func() {
-		ticker.Stop()
-		timer.Stop()
-	}()
+// check returns the status of the deployment of the service, which is tracked by the ID.
+// The deployment is rolled back only if the PRIMARY deployment which replaces it is of the previous task definition.
+func (w *Watch) check(service types.Service, deploymentID string, previousTaskDefinition string) Result {
+	serviceName := aws.ToString(service.ServiceName)
+	result := Result{
+		Cluster:      w.Cluster,
+		Service:      serviceName,
+		DeploymentID: deploymentID,
+	}
+	primary := primaryDeployment(service)
 
-CHECK:
-	result := w.check(ctx, ecsService, service)
+	var deployment *types.Deployment
+	for i, d := range service.Deployments {
+		if aws.ToString(d.Id) == deploymentID {
+			deployment = &service.Deployments[i]
+			break
+		}
+	}
+	if deployment == nil {
+		// The deployment is drained after it is replaced by another deployment, such as a rollback or a newer deploy
+		switch {
+		case primary == nil:
+			result.Status = DeployFailed
+			result.Error = fmt.Errorf("deployment %s is not found", deploymentID)
+		case isRollback(primary, previousTaskDefinition):
+			result.Status = RolledBack
+			result.Error = fmt.Errorf("deployment %s is rolled back by %s to %s", deploymentID, aws.ToString(primary.Id), previousTaskDefinition)
+		default:
+			result.Status = Superseded
+			result.Error = fmt.Errorf("deployment %s is superseded by %s of %s", deploymentID, aws.ToString(primary.Id), aws.ToString(primary.TaskDefinition))
+		}
+		return result
+	}
+	result.TaskDefinition = aws.ToString(deployment.TaskDefinition)
+	result.RolloutState = deployment.RolloutState
+	result.Reason = aws.ToString(deployment.RolloutStateReason)
+	result.DesiredCount = deployment.DesiredCount
+	result.RunningCount = deployment.RunningCount
+	result.PendingCount = deployment.PendingCount
+	result.FailedTasks = deployment.FailedTasks
+	logrus.Debugf("deployment [cluster: %s, service: %s, id: %s]: rolloutState: %s, running: %d/%d, failed: %d, reason: %s",
+		w.Cluster, serviceName, deploymentID, result.RolloutState, result.RunningCount, result.DesiredCount, result.FailedTasks, result.Reason)
 
-	switch result.Status {
-	case Deployed, DeployFailed, Error:
-		w.Results <- result
-		return
-	case Deploying:
-		// noop
+	switch deployment.RolloutState {
+	case types.DeploymentRolloutStateCompleted:
+		result.Status = Deployed
+	case types.DeploymentRolloutStateFailed:
+		result.Status = DeployFailed
+		if primary != nil && aws.ToString(primary.Id) != deploymentID && rollbackEnabled(service) && isRollback(primary, previousTaskDefinition) {
+			result.Status = RolledBack
+		}
+		result.Error = fmt.Errorf("deployment %s is failed: %s", deploymentID, result.Reason)
+	case types.DeploymentRolloutStateInProgress:
+		result.Status = Deploying
+	default:
+		// Deployments which are not managed by the ECS deployment controller have no rollout state
+		result.Status = Deploying
+		if aws.ToString(deployment.Status) == deploymentStatusPrimary && len(service.Deployments) == 1 && deployment.RunningCount == deployment.DesiredCount {
+			result.Status = Deployed
+		}
 	}
+	return result
+}
 
-	select {
-	case <-ticker.C:
-		goto CHECK
-	case <-timer.C:
-		logrus.Errorf("timeout [cluster: %s, service: %s]", w.Cluster, *service.ServiceName)
-		w.Results <- Result{
-			Cluster: w.Cluster,
-			Service: *service.ServiceName,
-			Status:  Timeout,
-			Error:   nil,
+// previousTaskDefinition returns the task definition of the latest deployment which is not PRIMARY.
+func previousTaskDefinition(service types.Service) string {
+	var previous *types.Deployment
+	for i, d := range service.Deployments {
+		if aws.ToString(d.Status) == deploymentStatusPrimary {
+			continue
+		}
+		if previous == nil || aws.ToTime(d.CreatedAt).After(aws.ToTime(previous.CreatedAt)) {
+			previous = &service.Deployments[i]
 		}
-		return
 	}
+	if previous == nil {
+		return ""
+	}
+	return aws.ToString(previous.TaskDefinition)
+}
+
+func primaryDeployment(service types.Service) *types.Deployment {
+	for i, d := range service.Deployments {
+		if aws.ToString(d.Status) == deploymentStatusPrimary {
+			return &service.Deployments[i]
+		}
+	}
+	return nil
+}
+
+// isRollback returns whether the PRIMARY deployment rolls the service back to the previous task definition.
+func isRollback(primary *types.Deployment, previousTaskDefinition string) bool {
+	return previousTaskDefinition != "" && aws.ToString(primary.TaskDefinition) == previousTaskDefinition
+}
+
+func rollbackEnabled(service types.Service) bool {
+	dc := service.DeploymentConfiguration
+	return dc != nil && dc.DeploymentCircuitBreaker != nil && dc.DeploymentCircuitBreaker.Enable && dc.DeploymentCircuitBreaker.Rollback
 }
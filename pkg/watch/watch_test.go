@@ -0,0 +1,391 @@
+package watch_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/kazz187/fargate-td/pkg/watch"
+	"github.com/kazz187/fargate-td/pkg/watch/watchtest"
+)
+
+const (
+	testCluster  = "cluster"
+	testInterval = 10 * time.Second
+	testTimeout  = time.Minute
+)
+
+var testStart = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func taskDefinitionArn(revision int) string {
+	return fmt.Sprintf("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/web:%d", revision)
+}
+
+func deployment(id, status string, revision int, state types.DeploymentRolloutState) types.Deployment {
+	return types.Deployment{
+		Id:             aws.String(id),
+		Status:         aws.String(status),
+		TaskDefinition: aws.String(taskDefinitionArn(revision)),
+		RolloutState:   state,
+		DesiredCount:   2,
+		CreatedAt:      aws.Time(testStart),
+	}
+}
+
+func service(name string, deployments ...types.Deployment) types.Service {
+	return types.Service{
+		ServiceName: aws.String(name),
+		Deployments: deployments,
+		DeploymentConfiguration: &types.DeploymentConfiguration{
+			DeploymentCircuitBreaker: &types.DeploymentCircuitBreaker{Enable: true, Rollback: true},
+		},
+	}
+}
+
+func stoppedTasks(deploymentID string, n int) []types.Task {
+	tasks := make([]types.Task, 0, n)
+	for i := range n {
+		tasks = append(tasks, types.Task{
+			TaskArn:           aws.String(fmt.Sprintf("arn:aws:ecs:ap-northeast-1:123456789012:task/%s/%03d", testCluster, i)),
+			TaskDefinitionArn: aws.String(taskDefinitionArn(2)),
+			StartedBy:         aws.String(deploymentID),
+			DesiredStatus:     aws.String("STOPPED"),
+			LastStatus:        aws.String("STOPPED"),
+			StopCode:          types.TaskStopCodeEssentialContainerExited,
+			StoppedAt:         aws.Time(testStart.Add(time.Duration(i) * time.Second)),
+			Containers:        []types.Container{{Name: aws.String("web"), ExitCode: aws.Int32(1)}},
+		})
+	}
+	return tasks
+}
+
+func TestWatch(t *testing.T) {
+	inProgress := service("web",
+		deployment("ecs-svc/2", "PRIMARY", 2, types.DeploymentRolloutStateInProgress),
+		deployment("ecs-svc/1", "ACTIVE", 1, types.DeploymentRolloutStateCompleted),
+	)
+	tests := []struct {
+		name     string
+		services []types.Service
+		tasks    []types.Task
+		bakes    map[string]watch.Bake
+		// steps change the services before each check after the first one
+		steps []func(f *watchtest.ECS)
+		// cancel cancels the watch after the steps
+		cancel bool
+		want   map[string]int
+		check  func(t *testing.T, f *watchtest.ECS, results map[string]watch.Result)
+	}{
+		{
+			name:     "completed",
+			services: []types.Service{inProgress},
+			steps: []func(f *watchtest.ECS){
+				func(f *watchtest.ECS) {
+					f.PutService(testCluster, service("web",
+						deployment("ecs-svc/2", "PRIMARY", 2, types.DeploymentRolloutStateCompleted),
+					))
+				},
+			},
+			want: map[string]int{"web": watch.Deployed},
+		},
+		{
+			name:     "rolled back by the circuit breaker",
+			services: []types.Service{inProgress},
+			steps: []func(f *watchtest.ECS){
+				func(f *watchtest.ECS) {
+					f.PutService(testCluster, service("web",
+						deployment("ecs-svc/3", "PRIMARY", 1, types.DeploymentRolloutStateInProgress),
+						deployment("ecs-svc/2", "ACTIVE", 2, types.DeploymentRolloutStateFailed),
+					))
+				},
+			},
+			want: map[string]int{"web": watch.RolledBack},
+		},
+		{
+			name: "rolled back to the task definition before the deploy after the previous deployment is drained",
+			services: []types.Service{service("web",
+				deployment("ecs-svc/2", "PRIMARY", 2, types.DeploymentRolloutStateInProgress),
+			)},
+			bakes: map[string]watch.Bake{"web": {PreviousTaskDefinition: taskDefinitionArn(1)}},
+			steps: []func(f *watchtest.ECS){
+				func(f *watchtest.ECS) {
+					f.PutService(testCluster, service("web",
+						deployment("ecs-svc/3", "PRIMARY", 1, types.DeploymentRolloutStateInProgress),
+					))
+				},
+			},
+			want: map[string]int{"web": watch.RolledBack},
+		},
+		{
+			name: "error at the start if the task definition to roll back to is not found",
+			services: []types.Service{service("web",
+				deployment("ecs-svc/2", "PRIMARY", 2, types.DeploymentRolloutStateInProgress),
+			)},
+			bakes: map[string]watch.Bake{"web": {Duration: 10 * time.Minute, Alarms: []string{"web-5xx"}, Rollback: true}},
+			want:  map[string]int{"web": watch.Error},
+			check: func(t *testing.T, f *watchtest.ECS, results map[string]watch.Result) {
+				// Only when the watch is started
+				if got := f.Calls("DescribeServices"); got != 1 {
+					t.Errorf("DescribeServices is called %d times, want 1", got)
+				}
+			},
+		},
+		{
+			name:     "superseded by a newer deploy",
+			services: []types.Service{inProgress},
+			steps: []func(f *watchtest.ECS){
+				func(f *watchtest.ECS) {
+					f.PutService(testCluster, service("web",
+						deployment("ecs-svc/3", "PRIMARY", 3, types.DeploymentRolloutStateInProgress),
+					))
+				},
+			},
+			want: map[string]int{"web": watch.Superseded},
+		},
+		{
+			name:     "timeout",
+			services: []types.Service{inProgress},
+			tasks:    stoppedTasks("ecs-svc/2", 1),
+			steps:    make([]func(f *watchtest.ECS), int(testTimeout/testInterval)),
+			want:     map[string]int{"web": watch.Timeout},
+			check: func(t *testing.T, f *watchtest.ECS, results map[string]watch.Result) {
+				if got := len(results["web"].StoppedTasks); got != 1 {
+					t.Errorf("len(StoppedTasks) = %d, want 1", got)
+				}
+			},
+		},
+		{
+			name:     "cancel",
+			services: []types.Service{inProgress},
+			steps:    make([]func(f *watchtest.ECS), 1),
+			cancel:   true,
+			want:     map[string]int{"web": watch.Canceled},
+			check: func(t *testing.T, f *watchtest.ECS, results map[string]watch.Result) {
+				if err := results["web"].Error; !errors.Is(err, context.Canceled) {
+					t.Errorf("Error = %v, want %v", err, context.Canceled)
+				}
+				if results["web"].DeploymentID != "ecs-svc/2" {
+					t.Errorf("DeploymentID = %q, want the last result of ecs-svc/2", results["web"].DeploymentID)
+				}
+			},
+		},
+		{
+			name: "more than 10 services are described in chunks",
+			services: func() []types.Service {
+				var services []types.Service
+				for i := range 11 {
+					services = append(services, service(fmt.Sprintf("web%02d", i),
+						deployment("ecs-svc/1", "PRIMARY", 1, types.DeploymentRolloutStateCompleted),
+					))
+				}
+				return services
+			}(),
+			want: func() map[string]int {
+				want := map[string]int{}
+				for i := range 11 {
+					want[fmt.Sprintf("web%02d", i)] = watch.Deployed
+				}
+				return want
+			}(),
+			check: func(t *testing.T, f *watchtest.ECS, results map[string]watch.Result) {
+				// Once when the watch is started, and once by the first check
+				if got := f.Calls("DescribeServices"); got != 4 {
+					t.Errorf("DescribeServices is called %d times, want 4", got)
+				}
+			},
+		},
+		{
+			name: "more than 100 stopped tasks are listed in pages and described in chunks",
+			services: []types.Service{service("web",
+				deployment("ecs-svc/2", "PRIMARY", 2, types.DeploymentRolloutStateFailed),
+			)},
+			tasks: stoppedTasks("ecs-svc/2", 150),
+			want:  map[string]int{"web": watch.DeployFailed},
+			check: func(t *testing.T, f *watchtest.ECS, results map[string]watch.Result) {
+				if got := f.Calls("ListTasks"); got != 2 {
+					t.Errorf("ListTasks is called %d times, want 2", got)
+				}
+				if got := f.Calls("DescribeTasks"); got != 2 {
+					t.Errorf("DescribeTasks is called %d times, want 2", got)
+				}
+				stopped := results["web"].StoppedTasks
+				if len(stopped) != 3 || stopped[0].TaskArn != aws.ToString(stoppedTasks("ecs-svc/2", 150)[149].TaskArn) {
+					t.Errorf("StoppedTasks = %+v, want the latest 3 tasks", stopped)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			f := watchtest.NewECS()
+			var names []string
+			for _, s := range tt.services {
+				f.PutService(testCluster, s)
+				names = append(names, aws.ToString(s.ServiceName))
+			}
+			for _, task := range tt.tasks {
+				f.PutTask(testCluster, task)
+			}
+			clock := watchtest.NewClock(testStart)
+			w := watch.NewWatch(testCluster, names, testInterval, testTimeout, watch.WithECS(f), watch.WithClock(clock))
+			w.Bakes = tt.bakes
+
+			results := map[string]watch.Result{}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for result := range w.Results {
+					if result.Status == watch.Progress {
+						continue
+					}
+					if _, ok := results[result.Service]; ok {
+						t.Errorf("result of service %s is sent twice: %+v", result.Service, result)
+					}
+					results[result.Service] = result
+				}
+			}()
+			go w.Start(ctx)
+			for _, step := range tt.steps {
+				clock.BlockUntil(1)
+				if step != nil {
+					step(f)
+				}
+				clock.Advance(testInterval)
+			}
+			if tt.cancel {
+				clock.BlockUntil(1)
+				cancel()
+			}
+			<-done
+
+			got := map[string]int{}
+			for name, result := range results {
+				got[name] = result.Status
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("statuses = %v, want %v", got, tt.want)
+			}
+			if tt.check != nil {
+				tt.check(t, f, results)
+			}
+		})
+	}
+}
+
+func TestTaskWatch(t *testing.T) {
+	const taskArn = "arn:aws:ecs:ap-northeast-1:123456789012:task/cluster/0123456789abcdef"
+	task := func(lastStatus string, containers ...types.Container) types.Task {
+		return types.Task{
+			TaskArn:           aws.String(taskArn),
+			TaskDefinitionArn: aws.String(taskDefinitionArn(1)),
+			LastStatus:        aws.String(lastStatus),
+			Containers:        containers,
+		}
+	}
+	exited := func(name string, code int32) types.Container {
+		return types.Container{Name: aws.String(name), ExitCode: aws.Int32(code)}
+	}
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		task    types.Task
+		// stopped stops the task with the containers after the steps, if it is set
+		stopped []types.Container
+		steps   int
+		want    int
+		// wantEssentials are the containers which are essential in the result
+		wantEssentials map[string]bool
+	}{
+		{
+			name:           "succeeded even if the non-essential container failed",
+			timeout:        testTimeout,
+			task:           task("RUNNING"),
+			stopped:        []types.Container{exited("app", 0), exited("sidecar", 1)},
+			steps:          1,
+			want:           watch.TaskSucceeded,
+			wantEssentials: map[string]bool{"app": true, "sidecar": false},
+		},
+		{
+			name:           "failed by the essential container",
+			timeout:        testTimeout,
+			task:           task("RUNNING"),
+			stopped:        []types.Container{exited("app", 2), exited("sidecar", 0)},
+			steps:          1,
+			want:           watch.TaskFailed,
+			wantEssentials: map[string]bool{"app": true, "sidecar": false},
+		},
+		{
+			name:    "timeout",
+			timeout: testTimeout,
+			task:    task("RUNNING"),
+			steps:   int(testTimeout / testInterval),
+			want:    watch.Timeout,
+		},
+		{
+			name:           "no timeout",
+			task:           task("RUNNING"),
+			stopped:        []types.Container{exited("app", 0)},
+			steps:          int(testTimeout/testInterval) * 2,
+			want:           watch.TaskSucceeded,
+			wantEssentials: map[string]bool{"app": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := watchtest.NewECS()
+			f.PutTask(testCluster, tt.task)
+			f.PutTaskDefinition(types.TaskDefinition{
+				TaskDefinitionArn: aws.String(taskDefinitionArn(1)),
+				ContainerDefinitions: []types.ContainerDefinition{
+					{Name: aws.String("app")},
+					{Name: aws.String("sidecar"), Essential: aws.Bool(false)},
+				},
+			})
+			clock := watchtest.NewClock(testStart)
+			w := watch.NewTaskWatch(testCluster, []string{taskArn}, testInterval, tt.timeout, watch.WithECS(f), watch.WithClock(clock))
+
+			var results []watch.TaskResult
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for result := range w.Results {
+					if result.Status != watch.Progress {
+						results = append(results, result)
+					}
+				}
+			}()
+			go w.Start(context.Background())
+			for range tt.steps {
+				clock.BlockUntil(1)
+				clock.Advance(testInterval)
+			}
+			if tt.stopped != nil {
+				clock.BlockUntil(1)
+				f.PutTask(testCluster, task("STOPPED", tt.stopped...))
+				clock.Advance(testInterval)
+			}
+			<-done
+
+			if len(results) != 1 || results[0].Status != tt.want {
+				t.Fatalf("results = %+v, want status %d", results, tt.want)
+			}
+			if tt.wantEssentials == nil {
+				return
+			}
+			essentials := map[string]bool{}
+			for _, c := range results[0].Stopped.Containers {
+				essentials[c.Name] = c.Essential
+			}
+			if fmt.Sprint(essentials) != fmt.Sprint(tt.wantEssentials) {
+				t.Errorf("essentials = %v, want %v", essentials, tt.wantEssentials)
+			}
+		})
+	}
+}
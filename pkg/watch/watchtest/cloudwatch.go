@@ -0,0 +1,62 @@
+package watchtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+
+	"github.com/kazz187/fargate-td/pkg/watch"
+)
+
+var _ watch.CloudWatchAPI = (*CloudWatch)(nil)
+
+// CloudWatch is an in-memory CloudWatch which implements watch.CloudWatchAPI.
+// Alarms are metric alarms.
+type CloudWatch struct {
+	mu     sync.Mutex
+	alarms map[string]cwtypes.MetricAlarm
+	err    error
+}
+
+func NewCloudWatch() *CloudWatch {
+	return &CloudWatch{
+		alarms: map[string]cwtypes.MetricAlarm{},
+	}
+}
+
+// SetAlarmState adds the alarm or changes its state.
+func (f *CloudWatch) SetAlarmState(name string, state cwtypes.StateValue, reason string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.alarms[name] = cwtypes.MetricAlarm{
+		AlarmName:   aws.String(name),
+		StateValue:  state,
+		StateReason: aws.String(reason),
+	}
+}
+
+// SetError makes all calls fail with err, until it is set to nil.
+func (f *CloudWatch) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// DescribeAlarms returns the alarms of AlarmNames in one page. Other parameters are ignored.
+func (f *CloudWatch) DescribeAlarms(ctx context.Context, params *cloudwatch.DescribeAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DescribeAlarmsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	out := &cloudwatch.DescribeAlarmsOutput{}
+	for _, name := range params.AlarmNames {
+		if a, ok := f.alarms[name]; ok {
+			out.MetricAlarms = append(out.MetricAlarms, a)
+		}
+	}
+	return out, nil
+}
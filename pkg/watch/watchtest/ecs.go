@@ -0,0 +1,254 @@
+// Package watchtest provides an in-memory ECS, Elastic Load Balancing v2, CloudWatch and a manual clock for testing programs which use pkg/watch.
+//
+// A rollout is simulated by putting services, and advancing the clock after the watch waits for the next check:
+//
+//	w := watch.NewWatch("cluster", []string{"web"}, 10*time.Second, time.Minute, watch.WithECS(fakeECS), watch.WithClock(clock))
+//	go w.Start(ctx)
+//	clock.BlockUntil(1)
+//	fakeECS.PutService("cluster", completedService)
+//	clock.Advance(10 * time.Second)
+//
+// With WithECS, the other clients are not created from the default AWS config.
+// Set ELBV2 by WithELBV2 if services have target groups, and CloudWatch by WithCloudWatch if bake times have alarms,
+// otherwise the services are reported as errors:
+//
+//	w := watch.NewWatch("cluster", []string{"web"}, 10*time.Second, time.Minute,
+//		watch.WithECS(fakeECS), watch.WithELBV2(fakeELBV2), watch.WithCloudWatch(fakeCloudWatch), watch.WithClock(clock))
+//
+// Results must be received concurrently, since the watch blocks when the channel is full.
+package watchtest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/kazz187/fargate-td/pkg/watch"
+)
+
+var _ watch.ECSAPI = (*ECS)(nil)
+
+// listTasksPageSize is the default number of tasks in a page of ListTasks.
+const listTasksPageSize = 100
+
+// ECS is an in-memory ECS which implements watch.ECSAPI.
+// Services and tasks are stored as they are put, and must not be modified after that.
+// Put a new value to change them between checks of the watch.
+type ECS struct {
+	mu       sync.Mutex
+	services map[string]map[string]types.Service
+	tasks    map[string][]types.Task
+	// taskDefinitions are the task definitions by ARN
+	taskDefinitions map[string]types.TaskDefinition
+	err             error
+	calls           map[string]int
+	// deployments is the number of the deployments which are started by UpdateService
+	deployments int
+}
+
+func NewECS() *ECS {
+	return &ECS{
+		services:        map[string]map[string]types.Service{},
+		tasks:           map[string][]types.Task{},
+		taskDefinitions: map[string]types.TaskDefinition{},
+		calls:           map[string]int{},
+	}
+}
+
+// PutService adds or replaces the service of the cluster by its name.
+func (f *ECS) PutService(cluster string, service types.Service) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.services[cluster] == nil {
+		f.services[cluster] = map[string]types.Service{}
+	}
+	f.services[cluster][aws.ToString(service.ServiceName)] = service
+}
+
+// PutTask adds or replaces the task of the cluster by its ARN.
+func (f *ECS) PutTask(cluster string, task types.Task) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, t := range f.tasks[cluster] {
+		if aws.ToString(t.TaskArn) == aws.ToString(task.TaskArn) {
+			f.tasks[cluster][i] = task
+			return
+		}
+	}
+	f.tasks[cluster] = append(f.tasks[cluster], task)
+}
+
+// PutTaskDefinition adds or replaces the task definition by its ARN.
+func (f *ECS) PutTaskDefinition(td types.TaskDefinition) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.taskDefinitions[aws.ToString(td.TaskDefinitionArn)] = td
+}
+
+// SetError makes all calls fail with err, until it is set to nil.
+func (f *ECS) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// Calls returns the number of calls of the operation, such as "DescribeServices".
+func (f *ECS) Calls(operation string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[operation]
+}
+
+func (f *ECS) call(operation string) error {
+	f.calls[operation]++
+	return f.err
+}
+
+func (f *ECS) DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("DescribeServices"); err != nil {
+		return nil, err
+	}
+	out := &ecs.DescribeServicesOutput{}
+	for _, name := range params.Services {
+		// Services are described by name or ARN
+		service, ok := f.services[aws.ToString(params.Cluster)][name[strings.LastIndex(name, "/")+1:]]
+		if !ok {
+			out.Failures = append(out.Failures, types.Failure{
+				Arn:    aws.String(name),
+				Reason: aws.String("MISSING"),
+			})
+			continue
+		}
+		out.Services = append(out.Services, service)
+	}
+	return out, nil
+}
+
+// ListTasks returns the tasks which match StartedBy, ServiceName, Family and DesiredStatus (default: RUNNING)
+// in pages of MaxResults (default: 100) as ECS does, and NextToken is the index of the next task.
+func (f *ECS) ListTasks(ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("ListTasks"); err != nil {
+		return nil, err
+	}
+	desiredStatus := params.DesiredStatus
+	if desiredStatus == "" {
+		desiredStatus = types.DesiredStatusRunning
+	}
+	var taskArns []string
+	for _, t := range f.tasks[aws.ToString(params.Cluster)] {
+		if params.StartedBy != nil && aws.ToString(t.StartedBy) != *params.StartedBy {
+			continue
+		}
+		if params.ServiceName != nil && aws.ToString(t.Group) != "service:"+*params.ServiceName {
+			continue
+		}
+		// The task definition ARN is "arn:aws:ecs:region:account:task-definition/family:revision"
+		if params.Family != nil && !strings.Contains(aws.ToString(t.TaskDefinitionArn), "task-definition/"+*params.Family+":") {
+			continue
+		}
+		if aws.ToString(t.DesiredStatus) != string(desiredStatus) {
+			continue
+		}
+		taskArns = append(taskArns, aws.ToString(t.TaskArn))
+	}
+	start := 0
+	if params.NextToken != nil {
+		var err error
+		if start, err = strconv.Atoi(*params.NextToken); err != nil || start < 0 || start > len(taskArns) {
+			return nil, &types.InvalidParameterException{Message: aws.String("Invalid nextToken.")}
+		}
+	}
+	pageSize := listTasksPageSize
+	if params.MaxResults != nil {
+		pageSize = int(*params.MaxResults)
+	}
+	end := min(start+pageSize, len(taskArns))
+	out := &ecs.ListTasksOutput{TaskArns: taskArns[start:end]}
+	if end < len(taskArns) {
+		out.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return out, nil
+}
+
+func (f *ECS) DescribeTasks(ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("DescribeTasks"); err != nil {
+		return nil, err
+	}
+	tasks := map[string]types.Task{}
+	for _, t := range f.tasks[aws.ToString(params.Cluster)] {
+		tasks[aws.ToString(t.TaskArn)] = t
+	}
+	out := &ecs.DescribeTasksOutput{}
+	for _, arn := range params.Tasks {
+		t, ok := tasks[arn]
+		if !ok {
+			out.Failures = append(out.Failures, types.Failure{
+				Arn:    aws.String(arn),
+				Reason: aws.String("MISSING"),
+			})
+			continue
+		}
+		out.Tasks = append(out.Tasks, t)
+	}
+	return out, nil
+}
+
+func (f *ECS) DescribeTaskDefinition(ctx context.Context, params *ecs.DescribeTaskDefinitionInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTaskDefinitionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("DescribeTaskDefinition"); err != nil {
+		return nil, err
+	}
+	td, ok := f.taskDefinitions[aws.ToString(params.TaskDefinition)]
+	if !ok {
+		return nil, &types.ClientException{Message: aws.String("Unable to describe task definition.")}
+	}
+	return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: &td}, nil
+}
+
+// UpdateService starts a new PRIMARY deployment of the task definition in progress, and makes the PRIMARY one ACTIVE.
+// Other parameters are ignored.
+func (f *ECS) UpdateService(ctx context.Context, params *ecs.UpdateServiceInput, optFns ...func(*ecs.Options)) (*ecs.UpdateServiceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("UpdateService"); err != nil {
+		return nil, err
+	}
+	cluster, name := aws.ToString(params.Cluster), aws.ToString(params.Service)
+	service, ok := f.services[cluster][name]
+	if !ok {
+		return nil, &types.ServiceNotFoundException{Message: aws.String(fmt.Sprintf("service %s is not found", name))}
+	}
+	if params.TaskDefinition != nil {
+		f.deployments++
+		deployments := []types.Deployment{{
+			Id:             aws.String(fmt.Sprintf("ecs-svc/fake-%d", f.deployments)),
+			Status:         aws.String("PRIMARY"),
+			TaskDefinition: params.TaskDefinition,
+			DesiredCount:   service.DesiredCount,
+			RolloutState:   types.DeploymentRolloutStateInProgress,
+		}}
+		for _, d := range service.Deployments {
+			if aws.ToString(d.Status) == "PRIMARY" {
+				d.Status = aws.String("ACTIVE")
+			}
+			deployments = append(deployments, d)
+		}
+		service.Deployments = deployments
+		service.TaskDefinition = params.TaskDefinition
+		f.services[cluster][name] = service
+	}
+	return &ecs.UpdateServiceOutput{Service: &service}, nil
+}
@@ -0,0 +1,82 @@
+package watchtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+
+	"github.com/kazz187/fargate-td/pkg/watch"
+)
+
+var _ watch.ELBV2API = (*ELBV2)(nil)
+
+// ELBV2 is an in-memory Elastic Load Balancing v2 which implements watch.ELBV2API.
+type ELBV2 struct {
+	mu      sync.Mutex
+	targets map[string][]elbtypes.TargetHealthDescription
+	err     error
+}
+
+func NewELBV2() *ELBV2 {
+	return &ELBV2{
+		targets: map[string][]elbtypes.TargetHealthDescription{},
+	}
+}
+
+// PutTarget adds or replaces the IP target of the target group by its IP and port.
+func (f *ELBV2) PutTarget(targetGroupArn, ip string, port int32, state elbtypes.TargetHealthStateEnum) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := elbtypes.TargetHealthDescription{
+		Target: &elbtypes.TargetDescription{
+			Id:   aws.String(ip),
+			Port: aws.Int32(port),
+		},
+		TargetHealth: &elbtypes.TargetHealth{
+			State: state,
+		},
+	}
+	for i, t := range f.targets[targetGroupArn] {
+		if aws.ToString(t.Target.Id) == ip && aws.ToInt32(t.Target.Port) == port {
+			f.targets[targetGroupArn][i] = d
+			return
+		}
+	}
+	f.targets[targetGroupArn] = append(f.targets[targetGroupArn], d)
+}
+
+// RemoveTarget deregisters the IP target from the target group.
+func (f *ELBV2) RemoveTarget(targetGroupArn, ip string, port int32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	targets := f.targets[targetGroupArn][:0]
+	for _, t := range f.targets[targetGroupArn] {
+		if aws.ToString(t.Target.Id) == ip && aws.ToInt32(t.Target.Port) == port {
+			continue
+		}
+		targets = append(targets, t)
+	}
+	f.targets[targetGroupArn] = targets
+}
+
+// SetError makes all calls fail with err, until it is set to nil.
+func (f *ELBV2) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *ELBV2) DescribeTargetHealth(ctx context.Context, params *elbv2.DescribeTargetHealthInput, optFns ...func(*elbv2.Options)) (*elbv2.DescribeTargetHealthOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	targets := f.targets[aws.ToString(params.TargetGroupArn)]
+	return &elbv2.DescribeTargetHealthOutput{
+		TargetHealthDescriptions: append([]elbtypes.TargetHealthDescription(nil), targets...),
+	}, nil
+}